@@ -7,6 +7,20 @@ const (
 	EnableMouseTracking            = "\x1b[?1003h"
 	DisableMouseTracking           = "\x1b[?1003l"
 	DisableNormalMouseTracking     = "\x1b[?1000l"
+	EnableBracketedPaste           = "\x1b[?2004h"
+	DisableBracketedPaste          = "\x1b[?2004l"
+	EnableFocusReporting           = "\x1b[?1004h"
+	DisableFocusReporting          = "\x1b[?1004l"
+
+	BracketedPasteStart = "\x1b[200~"
+	BracketedPasteEnd   = "\x1b[201~"
+	FocusIn             = "\x1b[I"
+	FocusOut            = "\x1b[O"
+
+	// OSC 52 is followed by <selection>;<base64 data or ?> then BEL or ST
+	OSC52 = "\x1b]52;"
+	BEL   = "\x07"
+	ST    = "\x1b\\"
 
 	HideCursor = "\x1b[?25l"
 	ShowCursor = "\x1b[?25h"
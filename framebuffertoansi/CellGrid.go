@@ -0,0 +1,198 @@
+package framebuffertoansi
+
+// #cgo pkg-config: chafa glib-2.0
+// #include "chafa.h"
+import "C"
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mmulet/term.everything/escapecodes"
+)
+
+/**
+ * One character cell of the canvas.
+ * Fg and Bg are packed 24 bit rgb in truecolor mode
+ * and palette indexes otherwise, -1 is the default color.
+ * Symbol is 0 for the right half of a wide character.
+ */
+type Cell struct {
+	Symbol rune
+	Fg     int32
+	Bg     int32
+}
+
+/**
+ * The cells we last put on the terminal,
+ * so the next frame only has to send what changed.
+ */
+type CellGrid struct {
+	WidthCells  int
+	HeightCells int
+	/**
+	 * Terminal row of the first canvas row (0 based),
+	 * it moves down when there is a status line.
+	 */
+	Top   int
+	Cells []Cell
+}
+
+/**
+ * If more than this fraction of the cells changed,
+ * a full repaint is smaller than positioning the
+ * cursor in front of each of them.
+ */
+const MaxDiffFraction = 0.5
+
+/**
+ * Only symbol output is made of cells we can diff,
+ * and we only know how to write out the colors of
+ * the modes with separate fg and bg colors.
+ */
+func (ci *ChafaInfo) CanDiff() bool {
+	if ci.PixelMode != C.CHAFA_PIXEL_MODE_SYMBOLS {
+		return false
+	}
+	switch ci.Mode {
+	case C.CHAFA_CANVAS_MODE_TRUECOLOR,
+		C.CHAFA_CANVAS_MODE_INDEXED_256,
+		C.CHAFA_CANVAS_MODE_INDEXED_240,
+		C.CHAFA_CANVAS_MODE_INDEXED_16,
+		C.CHAFA_CANVAS_MODE_INDEXED_16_8,
+		C.CHAFA_CANVAS_MODE_INDEXED_8:
+		return true
+	}
+	return false
+}
+
+/**
+ * Read the canvas, after Draw, into cells
+ */
+func (ci *ChafaInfo) ReadCells(cells []Cell) {
+	truecolor := ci.Mode == C.CHAFA_CANVAS_MODE_TRUECOLOR
+	for y := 0; y < ci.HeightCells; y++ {
+		for x := 0; x < ci.WidthCells; x++ {
+			var fg, bg C.gint
+			if truecolor {
+				C.chafa_canvas_get_colors_at(ci.Canvas, C.gint(x), C.gint(y), &fg, &bg)
+			} else {
+				C.chafa_canvas_get_raw_colors_at(ci.Canvas, C.gint(x), C.gint(y), &fg, &bg)
+			}
+			cells[y*ci.WidthCells+x] = Cell{
+				Symbol: rune(C.chafa_canvas_get_char_at(ci.Canvas, C.gint(x), C.gint(y))),
+				Fg:     int32(fg),
+				Bg:     int32(bg),
+			}
+		}
+	}
+}
+
+/**
+ * Cursor movement and cells to go from the grid to cells,
+ * ok is false when a full repaint is the better choice.
+ */
+func (g *CellGrid) Diff(cells []Cell, truecolor bool) (out string, ok bool) {
+	changed := make([]bool, len(cells))
+	numChanged := 0
+	for i := range cells {
+		if cells[i] == g.Cells[i] {
+			continue
+		}
+		changed[i] = true
+		numChanged++
+		/**
+		 * The right half of a wide character is
+		 * drawn by writing the left half.
+		 */
+		if cells[i].Symbol == 0 && i%g.WidthCells > 0 {
+			changed[i-1] = true
+		}
+	}
+	if float64(numChanged) > MaxDiffFraction*float64(len(cells)) {
+		return "", false
+	}
+	if numChanged == 0 {
+		return "", true
+	}
+
+	var sb strings.Builder
+	sb.WriteString(escapecodes.Reset)
+	cursorX, cursorY := -1, -1
+	var fg, bg int32 = -2, -2
+	for y := 0; y < g.HeightCells; y++ {
+		for x := 0; x < g.WidthCells; x++ {
+			i := y*g.WidthCells + x
+			cell := cells[i]
+			if !changed[i] || cell.Symbol == 0 {
+				continue
+			}
+			if cursorX != x || cursorY != y {
+				sb.WriteString(MoveCursorTo(g.Top+y, x))
+			}
+			if cell.Fg != fg {
+				sb.WriteString(ColorCode(cell.Fg, true, truecolor))
+				fg = cell.Fg
+			}
+			if cell.Bg != bg {
+				sb.WriteString(ColorCode(cell.Bg, false, truecolor))
+				bg = cell.Bg
+			}
+			sb.WriteRune(cell.Symbol)
+			cursorX, cursorY = x+1, y
+			if x+1 < g.WidthCells && cells[i+1].Symbol == 0 {
+				cursorX++
+			}
+		}
+	}
+	sb.WriteString(escapecodes.Reset)
+	return sb.String(), true
+}
+
+/**
+ * row and col are 0 based
+ */
+func MoveCursorTo(row, col int) string {
+	return "\x1b[" + strconv.Itoa(row+1) + ";" + strconv.Itoa(col+1) + "H"
+}
+
+func ColorCode(color int32, foreground bool, truecolor bool) string {
+	switch {
+	/**
+	 * Palette indexes past 255 are chafa's
+	 * transparent, fg and bg specials.
+	 */
+	case color < 0, !truecolor && color > 255:
+		if foreground {
+			return "\x1b[39m"
+		}
+		return "\x1b[49m"
+	case truecolor:
+		kind := "48;2;"
+		if foreground {
+			kind = "38;2;"
+		}
+		return "\x1b[" + kind +
+			strconv.Itoa(int(color>>16&0xff)) + ";" +
+			strconv.Itoa(int(color>>8&0xff)) + ";" +
+			strconv.Itoa(int(color&0xff)) + "m"
+	case color < 8:
+		base := 40
+		if foreground {
+			base = 30
+		}
+		return "\x1b[" + strconv.Itoa(base+int(color)) + "m"
+	case color < 16:
+		base := 100
+		if foreground {
+			base = 90
+		}
+		return "\x1b[" + strconv.Itoa(base+int(color)-8) + "m"
+	default:
+		kind := "48;5;"
+		if foreground {
+			kind = "38;5;"
+		}
+		return "\x1b[" + kind + strconv.Itoa(int(color)) + "m"
+	}
+}
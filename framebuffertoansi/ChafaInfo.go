@@ -45,6 +45,17 @@ func (ci *ChafaInfo) ConvertImage(texturePixels []byte, textureWidth, textureHei
 	if len(texturePixels) == 0 {
 		return ""
 	}
+	ci.Draw(texturePixels, textureWidth, textureHeight, textureStride)
+	return ci.Print()
+}
+
+/**
+ * Draw the pixels onto the canvas
+ */
+func (ci *ChafaInfo) Draw(texturePixels []byte, textureWidth, textureHeight, textureStride uint32) {
+	if len(texturePixels) == 0 {
+		return
+	}
 
 	pixelsPtr := (*C.guint8)(unsafe.Pointer(&texturePixels[0]))
 
@@ -57,7 +68,12 @@ func (ci *ChafaInfo) ConvertImage(texturePixels []byte, textureWidth, textureHei
 		C.int(textureStride),
 	)
 	runtime.KeepAlive(texturePixels)
+}
 
+/**
+ * The whole canvas as escape codes
+ */
+func (ci *ChafaInfo) Print() string {
 	gstr := C.chafa_canvas_print(ci.Canvas, ci.TermInfo)
 	if gstr == nil {
 		return ""
@@ -72,8 +88,8 @@ func (ci *ChafaInfo) ConvertImage(texturePixels []byte, textureWidth, textureHei
 	return C.GoStringN((*C.char)(unsafe.Pointer(ptrStr)), C.int(length))
 }
 
-func MakeChafaInfo(widthCells, heightCells, widthOfACellInPixels, heightOfACellInPixels int, sessionTypeIsX11 bool) *ChafaInfo {
-	termInfo, mode, pixelMode := DetectTerminal()
+func MakeChafaInfo(widthCells, heightCells, widthOfACellInPixels, heightOfACellInPixels int, sessionTypeIsX11 bool, pixelModeOverride string) *ChafaInfo {
+	termInfo, mode, pixelMode := DetectTerminal(pixelModeOverride)
 
 	ci := &ChafaInfo{
 		TermInfo:              termInfo,
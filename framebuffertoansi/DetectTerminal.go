@@ -33,8 +33,16 @@ func getCanvasModeOverride() string {
 	return os.Getenv("TERM_EVERYTHING_CANVAS_MODE")
 }
 
-func getPixelMode(termInfo *C.ChafaTermInfo) C.ChafaPixelMode {
-	override := getPixelModeOverride()
+/**
+ * Pixel modes that can be asked for, "" is
+ * TERM_EVERYTHING_PIXEL_MODE or detected.
+ */
+var PixelModes = []string{"", "SYMBOLS", "SIXELS", "KITTY", "ITERM2"}
+
+func getPixelMode(termInfo *C.ChafaTermInfo, override string) C.ChafaPixelMode {
+	if override == "" {
+		override = getPixelModeOverride()
+	}
 	if override == "" {
 		return getDefaultPixelMode(termInfo)
 	}
@@ -107,9 +115,13 @@ func getCanvasMode(termInfo *C.ChafaTermInfo, pixelMode C.ChafaPixelMode) C.Chaf
 	}
 }
 
-func DetectTerminal() (termInfo *C.ChafaTermInfo, mode C.ChafaCanvasMode, pixelMode C.ChafaPixelMode) {
+/**
+ * pixelModeOverride is one of PixelModes
+ */
+func DetectTerminal(pixelModeOverride string) (termInfo *C.ChafaTermInfo, mode C.ChafaCanvasMode, pixelMode C.ChafaPixelMode) {
 	termInfo = C.detect_term_info_from_env()
-	if getPixelModeOverride() != "" ||
+	if pixelModeOverride != "" ||
+		getPixelModeOverride() != "" ||
 		getCanvasModeOverride() != "" {
 		/* Make sure we have fallback sequences in case the user forces
 		 * a mode that's technically unsupported by the terminal. */
@@ -118,7 +130,7 @@ func DetectTerminal() (termInfo *C.ChafaTermInfo, mode C.ChafaCanvasMode, pixelM
 		C.chafa_term_info_unref(fallback_info)
 	}
 
-	pixelMode = getPixelMode(termInfo)
+	pixelMode = getPixelMode(termInfo, pixelModeOverride)
 	mode = getCanvasMode(termInfo, pixelMode)
 	return
 }
@@ -16,6 +16,29 @@ import (
 type DrawState struct {
 	SessionTypeIsX11 bool
 	ChafaInfo        *ChafaInfo
+	/**
+	 * What is on the terminal now, nil
+	 * when the next frame must repaint it all.
+	 */
+	LastFrame *CellGrid
+	/**
+	 * The terminal may reflow or clear the
+	 * screen when it is resized.
+	 */
+	LastTermSize TermSize
+	/**
+	 * Text drawn over the canvas last frame
+	 */
+	LastOverlay string
+	/**
+	 * One of PixelModes
+	 */
+	PixelModeOverride string
+	/**
+	 * Images from the last pixel mode
+	 * have to be cleared off the screen.
+	 */
+	NeedsClear bool
 }
 
 func MakeDrawState(sessionTypeIsX11 bool) *DrawState {
@@ -36,12 +59,27 @@ func (ds *DrawState) ResizeChafaInfoIfNeeded(WidthCells int, HeightCells int, te
 	if ds.ChafaInfo != nil {
 		return
 	}
+	ds.LastFrame = nil
 
 	ds.ChafaInfo = MakeChafaInfo(WidthCells,
 		HeightCells,
 		termSize.WidthOfACellInPixels,
 		termSize.HeightOfACellInPixels,
-		ds.SessionTypeIsX11)
+		ds.SessionTypeIsX11,
+		ds.PixelModeOverride)
+}
+
+/**
+ * Switch pixel modes, mode is one of PixelModes
+ */
+func (ds *DrawState) SetPixelModeOverride(mode string) {
+	if mode == ds.PixelModeOverride {
+		return
+	}
+	ds.PixelModeOverride = mode
+	ds.Destroy()
+	ds.LastFrame = nil
+	ds.NeedsClear = true
 }
 
 func (ds *DrawState) Destroy() {
@@ -51,7 +89,38 @@ func (ds *DrawState) Destroy() {
 	}
 }
 
-func (ds *DrawState) DrawDesktop(texturePixels []byte, width, height uint32, statusLine *string) (int, int) {
+/**
+ * Only the cells that changed since the last frame when
+ * we can, otherwise the whole canvas.
+ */
+func (ds *DrawState) CanvasOutput(top int) string {
+	ci := ds.ChafaInfo
+	if !ci.CanDiff() {
+		ds.LastFrame = nil
+		return MoveCursorTo(top, 0) + ci.Print()
+	}
+	cells := make([]Cell, ci.WidthCells*ci.HeightCells)
+	ci.ReadCells(cells)
+
+	last := ds.LastFrame
+	ds.LastFrame = &CellGrid{
+		WidthCells:  ci.WidthCells,
+		HeightCells: ci.HeightCells,
+		Top:         top,
+		Cells:       cells,
+	}
+	if last != nil && last.Top == top {
+		if diff, ok := last.Diff(cells, ci.Mode == C.CHAFA_CANVAS_MODE_TRUECOLOR); ok {
+			return diff
+		}
+	}
+	return MoveCursorTo(top, 0) + ci.Print()
+}
+
+/**
+ * overlay is drawn over the canvas, it must move the cursor itself.
+ */
+func (ds *DrawState) DrawDesktop(texturePixels []byte, width, height uint32, statusLine *string, overlay string) (int, int) {
 	haveStatusLine := statusLine != nil && len(*statusLine) > 0
 	termSize := MakeTermSize()
 
@@ -76,10 +145,25 @@ func (ds *DrawState) DrawDesktop(texturePixels []byte, width, height uint32, sta
 	)
 
 	ds.ResizeChafaInfoIfNeeded(widthCells, heightCells, termSize)
+	if termSize != ds.LastTermSize {
+		ds.LastFrame = nil
+		ds.LastTermSize = termSize
+	}
+	if overlay != ds.LastOverlay {
+		/**
+		 * Repaint what the old overlay covered
+		 */
+		ds.LastFrame = nil
+		ds.LastOverlay = overlay
+	}
 
-	printable := ds.ChafaInfo.ConvertImage(texturePixels, width, height, width*4)
+	ds.ChafaInfo.Draw(texturePixels, width, height, width*4)
 
 	var sb strings.Builder
+	if ds.NeedsClear {
+		sb.WriteString(escapecodes.ClearScreen)
+		ds.NeedsClear = false
+	}
 	if haveStatusLine {
 		sb.WriteString(escapecodes.MoveCursorToHome)
 		sb.WriteString(*statusLine)
@@ -87,7 +171,8 @@ func (ds *DrawState) DrawDesktop(texturePixels []byte, width, height uint32, sta
 		sb.WriteString("\n")
 
 	}
-	sb.WriteString(printable)
+	sb.WriteString(ds.CanvasOutput(statusLineHeight))
+	sb.WriteString(overlay)
 
 	fmt.Fprint(os.Stdout, sb.String())
 	_ = os.Stdout.Sync()
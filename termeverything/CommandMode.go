@@ -0,0 +1,115 @@
+package termeverything
+
+import (
+	"sync/atomic"
+
+	"github.com/mmulet/term.everything/framebuffertoansi"
+	"github.com/mmulet/term.everything/wayland"
+)
+
+/**
+ * Set by command mode in the input loop,
+ * read by the draw loop every frame.
+ */
+type DisplaySettings struct {
+	HideStatusBar atomic.Bool
+	/**
+	 * Index into framebuffertoansi.PixelModes
+	 */
+	PixelMode atomic.Int32
+	/**
+	 * Waiting for a command after the prefix key,
+	 * the status line shows the commands.
+	 */
+	CommandMode atomic.Bool
+}
+
+/**
+ * What the status line shows in command mode
+ */
+const CommandModeHelp = "q quit | n/p next/previous window | m minimize | s status bar | r render mode | Esc cancel"
+
+/**
+ * Like tmux, the prefix key starts command mode and the
+ * next key is a command. Every other key goes to the
+ * focused window, even during a keyboard grab the prefix
+ * works, so there is always a way out.
+ * Returns true if it used code. The clients must be locked.
+ */
+func (tw *TerminalWindow) CommandModeInput(code XkbdCode) bool {
+	key, ok := code.(*KeyCode)
+	if !ok {
+		return false
+	}
+	if tw.Mode == WindowMode_Passthrough {
+		if !tw.PrefixKey.Matches(key) {
+			return false
+		}
+		tw.Mode = WindowMode_Capture
+		tw.Display.CommandMode.Store(true)
+		return true
+	}
+	tw.Mode = WindowMode_Passthrough
+	tw.Display.CommandMode.Store(false)
+
+	if tw.PrefixKey.Matches(key) {
+		/**
+		 * The prefix twice sends it to the window
+		 */
+		return false
+	}
+	if key.Modifiers&^ModShift != 0 {
+		return true
+	}
+	switch key.KeyCode {
+	case KEY_N, KEY_TAB, KEY_P, KEY_M:
+		/**
+		 * A keyboard grab would keep focus where it is
+		 */
+		wayland.KeyboardFocus.BreakGrab()
+	}
+	switch key.KeyCode {
+	case KEY_Q:
+		GlobalExitChan <- 0
+	case KEY_N, KEY_TAB:
+		tw.CycleWindows(1)
+	case KEY_P:
+		tw.CycleWindows(-1)
+	case KEY_M:
+		for _, tab := range WindowTabs(tw.Clients) {
+			if tab.Focused && tab.Minimize != nil {
+				tab.Minimize()
+			}
+		}
+	case KEY_S:
+		tw.Display.HideStatusBar.Store(!tw.Display.HideStatusBar.Load())
+	case KEY_R:
+		next := (tw.Display.PixelMode.Load() + 1) % int32(len(framebuffertoansi.PixelModes))
+		tw.Display.PixelMode.Store(next)
+	}
+	return true
+}
+
+/**
+ * Show the window after (or before, for a negative step)
+ * the focused one, in status line order.
+ * The clients must be locked.
+ */
+func (tw *TerminalWindow) CycleWindows(step int) {
+	tabs := WindowTabs(tw.Clients)
+	if len(tabs) == 0 {
+		return
+	}
+	focused := -1
+	for i, tab := range tabs {
+		if tab.Focused {
+			focused = i
+			break
+		}
+	}
+	if focused == -1 && step < 0 {
+		focused = 0
+	}
+	tabs[((focused+step)%len(tabs)+len(tabs))%len(tabs)].Show()
+	wayland.Pointer.Refresh(tw.Clients)
+}
@@ -29,6 +29,16 @@ type Desktop struct {
 
 	CreatedAt                 time.Time
 	WillShowAppRightAtStartup bool
+
+	/**
+	 * What was drawn last frame, bottom to top
+	 */
+	LastFrame []DrawnSurface
+	/**
+	 * The parts of the back buffer that changed
+	 * in the last call to DrawClients
+	 */
+	Damage wayland.Region
 }
 
 func MakeDesktop(size wayland.Size, willShowAppRightAtStartup bool) *Desktop {
@@ -124,9 +134,14 @@ func (cd *Desktop) Clear() {
 }
 
 type SortedSurfaceEntry struct {
+	Client    *wayland.Client
 	Surface   *wayland.WlSurface
 	Src       *image.RGBA
 	SurfaceID protocols.ObjectID[protocols.WlSurface]
+	/**
+	 * Of the window it is part of
+	 */
+	Stacking wayland.WindowStacking
 }
 
 type SortedSurfaceEntryParentLocation struct {
@@ -138,12 +153,17 @@ func (cd *Desktop) DrawClients(clients []*wayland.Client) {
 
 	sorted := make([]SortedSurfaceEntry, 0, 64)
 
-	childToParent := make(map[protocols.ObjectID[protocols.WlSurface]]SortedSurfaceEntryParentLocation)
+	/**
+	 * Surface ids are per client, so is this
+	 */
+	childToParent := make(map[*wayland.Client]map[protocols.ObjectID[protocols.WlSurface]]SortedSurfaceEntryParentLocation)
 
 	for _, c := range clients {
 		if c == nil {
 			continue
 		}
+		parents := make(map[protocols.ObjectID[protocols.WlSurface]]SortedSurfaceEntryParentLocation)
+		childToParent[c] = parents
 		for surface_id := range c.DrawableSurfaces() {
 			surface := wayland.GetWlSurfaceObject(c, surface_id)
 			if surface == nil {
@@ -153,12 +173,15 @@ func (cd *Desktop) DrawClients(clients []*wayland.Client) {
 			if tex == nil {
 				continue
 			}
+			if _, isDragIcon := surface.Role.(*wayland.SurfaceRoleDragIcon); isDragIcon {
+				continue
+			}
 
 			for _, child := range surface.ChildrenInDrawOrder {
 				if child == nil {
 					continue
 				}
-				childToParent[*child] = SortedSurfaceEntryParentLocation{
+				parents[*child] = SortedSurfaceEntryParentLocation{
 					parentID: surface_id,
 					x:        int(surface.Position.X),
 					y:        int(surface.Position.Y),
@@ -166,6 +189,7 @@ func (cd *Desktop) DrawClients(clients []*wayland.Client) {
 			}
 
 			sorted = append(sorted, SortedSurfaceEntry{
+				Client:    c,
 				Surface:   surface,
 				Src:       tex,
 				SurfaceID: surface_id,
@@ -173,7 +197,35 @@ func (cd *Desktop) DrawClients(clients []*wayland.Client) {
 		}
 	}
 
+	/**
+	 * Minimized windows aren't drawn
+	 */
+	shown := sorted[:0]
+	for _, it := range sorted {
+		parents := childToParent[it.Client]
+		root := it.SurfaceID
+		parent, ok := parents[root]
+		for ok {
+			root = parent.parentID
+			parent, ok = parents[root]
+		}
+		stacking, minimized := wayland.SurfaceStacking(it.Client, root)
+		if minimized {
+			/**
+			 * It is redrawn in full when it is restored
+			 */
+			it.Surface.Damage = nil
+			continue
+		}
+		it.Stacking = stacking
+		shown = append(shown, it)
+	}
+	sorted = shown
+
 	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Stacking != sorted[j].Stacking {
+			return sorted[i].Stacking.Below(sorted[j].Stacking)
+		}
 		zi := sorted[i].Surface.Position.Z
 		zj := sorted[j].Surface.Position.Z
 		if zi == zj {
@@ -182,26 +234,175 @@ func (cd *Desktop) DrawClients(clients []*wayland.Client) {
 		return zi < zj
 	})
 
-	cd.Clear()
-
-	if len(sorted) == 0 && cd.AfterOpeningTimeout() {
-		cd.DrawImage(cd.IconImg, 0, 0)
+	if len(sorted) == 0 {
+		/**
+		 * Either blank or the icon, cheap enough
+		 * to redraw in full.
+		 */
+		cd.Clear()
+		if cd.AfterOpeningTimeout() {
+			cd.DrawImage(cd.IconImg, 0, 0)
+		}
+		cd.LastFrame = nil
+		cd.Damage = wayland.Region{}
+		cd.Damage.Add(cd.Bounds())
 		return
 	}
 
-	for _, it := range sorted {
+	positions := make([]image.Point, len(sorted))
+	for i, it := range sorted {
 		/**
 		 * Recursively get the position by adding
 		 * all ancestor position
 		 */
 		x := int(it.Surface.Position.X)
 		y := int(it.Surface.Position.Y)
-		parent, ok := childToParent[it.SurfaceID]
+		parents := childToParent[it.Client]
+		parent, ok := parents[it.SurfaceID]
 		for ok {
 			x += parent.x
 			y += parent.y
-			parent, ok = childToParent[parent.parentID]
+			parent, ok = parents[parent.parentID]
+		}
+		positions[i] = image.Pt(x, y)
+	}
+
+	/**
+	 * Going top down, skip anything completely
+	 * hidden behind the opaque regions above it.
+	 */
+	hidden := make([]bool, len(sorted))
+	covered := wayland.Region{}
+	for i := len(sorted) - 1; i >= 0; i-- {
+		it := sorted[i]
+		bounds := wayland.Rect{
+			X:      int32(positions[i].X),
+			Y:      int32(positions[i].Y),
+			Width:  int32(it.Src.Rect.Dx()),
+			Height: int32(it.Src.Rect.Dy()),
+		}
+		if covered.ContainsRect(bounds) {
+			hidden[i] = true
+			it.Surface.Damage = nil
+			continue
+		}
+		if it.Surface.OpaqueRegion == nil {
+			continue
+		}
+		for _, r := range it.Surface.OpaqueRegion.Translate(bounds.X, bounds.Y).Rects {
+			covered.Add(r.Intersect(bounds))
+		}
+	}
+
+	frame := make([]DrawnSurface, 0, len(sorted)+1)
+	for i, it := range sorted {
+		if hidden[i] {
+			continue
 		}
-		cd.DrawImage(it.Src, x, y)
+		frame = append(frame, DrawnSurface{
+			Surface: it.Surface,
+			Src:     it.Src,
+			At:      positions[i],
+		})
+	}
+
+	/**
+	 * The drag icon is drawn on top of everything, at the pointer
+	 */
+	if icon := wayland.DragAndDrop.IconSurface(); icon != nil {
+		if tex := icon.Texture.AsRGBA(); tex != nil {
+			frame = append(frame, DrawnSurface{
+				Surface: icon,
+				Src:     tex,
+				At: image.Pt(
+					int(wayland.Pointer.WindowX)+int(icon.Position.X),
+					int(wayland.Pointer.WindowY)+int(icon.Position.Y),
+				),
+			})
+		}
+	}
+
+	cd.Damage = cd.FrameDamage(frame)
+	cd.LastFrame = frame
+
+	for _, damaged := range cd.Damage.Rects {
+		clip := image.Rect(
+			int(damaged.X), int(damaged.Y),
+			int(damaged.X+damaged.Width), int(damaged.Y+damaged.Height),
+		)
+		draw.Draw(cd.RGBA, clip, image.Transparent, image.Point{}, draw.Src)
+		for _, it := range frame {
+			cd.DrawImageClipped(it.Src, it.At.X, it.At.Y, clip)
+		}
+	}
+}
+
+/**
+ * A surface as it was drawn in the last frame
+ */
+type DrawnSurface struct {
+	Surface *wayland.WlSurface
+	Src     *image.RGBA
+	At      image.Point
+}
+
+func (d DrawnSurface) Rect() wayland.Rect {
+	return wayland.Rect{
+		X:      int32(d.At.X),
+		Y:      int32(d.At.Y),
+		Width:  int32(d.Src.Rect.Dx()),
+		Height: int32(d.Src.Rect.Dy()),
+	}
+}
+
+func (cd *Desktop) Bounds() wayland.Rect {
+	return wayland.Rect{Width: int32(cd.Width), Height: int32(cd.Height)}
+}
+
+/**
+ * What has to be redrawn to go from LastFrame to frame:
+ * the damage the clients reported, and where surfaces
+ * appeared, moved, resized or went away.
+ * Clears each surface's damage.
+ */
+func (cd *Desktop) FrameDamage(frame []DrawnSurface) wayland.Region {
+	damage := wayland.Region{}
+	/**
+	 * A surface appeared or went away, just redraw everything
+	 */
+	if len(cd.LastFrame) != len(frame) {
+		for _, it := range frame {
+			it.Surface.Damage = nil
+		}
+		damage.Add(cd.Bounds())
+		return damage
+	}
+	for i, it := range frame {
+		last := cd.LastFrame[i]
+		if last.Surface != it.Surface || last.Rect() != it.Rect() {
+			/**
+			 * The stacking order changed or the surface
+			 * moved, redraw where it was and where it is.
+			 */
+			damage.Add(last.Rect().Intersect(cd.Bounds()))
+			damage.Add(it.Rect().Intersect(cd.Bounds()))
+			it.Surface.Damage = nil
+			continue
+		}
+		if it.Surface.Damage != nil {
+			for _, r := range it.Surface.Damage.Translate(int32(it.At.X), int32(it.At.Y)).Rects {
+				damage.Add(r.Intersect(it.Rect()).Intersect(cd.Bounds()))
+			}
+			it.Surface.Damage = nil
+		}
+	}
+	return damage
+}
+
+func (cd *Desktop) DrawImageClipped(src *image.RGBA, dx, dy int, clip image.Rectangle) {
+	r := image.Rect(dx, dy, dx+src.Rect.Dx(), dy+src.Rect.Dy()).Intersect(clip)
+	if r.Empty() {
+		return
 	}
+	draw.Draw(cd.RGBA, r, src, src.Rect.Min.Add(r.Min.Sub(image.Pt(dx, dy))), draw.Over)
 }
@@ -0,0 +1,270 @@
+package termeverything
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mmulet/term.everything/escapecodes"
+	"github.com/mmulet/term.everything/wayland"
+)
+
+/**
+ * Sequences the terminal sends us that are not
+ * key presses or mouse events.
+ */
+type HostInputSequence interface {
+	isHostInputSequence()
+}
+
+/**
+ * Text pasted into the terminal, between
+ * BracketedPasteStart and BracketedPasteEnd
+ */
+type HostPaste struct {
+	Data []byte
+}
+
+/**
+ * The terminal's reply to an OSC 52 query
+ */
+type HostClipboardReply struct {
+	Name string
+	Data []byte
+}
+
+type HostFocus struct {
+	Focused bool
+}
+
+func (*HostPaste) isHostInputSequence()          {}
+func (*HostClipboardReply) isHostInputSequence() {}
+func (*HostFocus) isHostInputSequence()          {}
+
+/**
+ * Don't buffer a paste or clipboard reply bigger than this,
+ * if the end never comes we give up and pass the bytes
+ * through as keys.
+ */
+const MaxHostInputSequenceSize = 8 << 20
+
+var hostSequenceStarts = []string{
+	escapecodes.BracketedPasteStart,
+	escapecodes.OSC52,
+	escapecodes.FocusIn,
+	escapecodes.FocusOut,
+}
+
+/**
+ * Pulls pastes, clipboard replies and focus changes out
+ * of stdin before the rest is turned into key codes.
+ * Pastes and replies can be split across many reads,
+ * so an unfinished one is kept until the next read.
+ */
+type HostInputSplitter struct {
+	pending []byte
+	/**
+	 * pending is only the start of a sequence, it may
+	 * be a key after all, see PartialHostSequenceTimeout.
+	 */
+	partial bool
+}
+
+/**
+ * How long to hold back what could be the start of a
+ * sequence (like "\x1b[2") before passing it on as keys.
+ */
+const PartialHostSequenceTimeout = 50 * time.Millisecond
+
+func (h *HostInputSplitter) Split(chunk []byte) (keys []byte, sequences []HostInputSequence) {
+	data := chunk
+	if len(h.pending) > 0 {
+		data = append(h.pending, chunk...)
+		h.pending = nil
+	}
+	h.partial = false
+	keys = make([]byte, 0, len(data))
+	i := 0
+	for i < len(data) {
+		if data[i] != 0x1b {
+			keys = append(keys, data[i])
+			i++
+			continue
+		}
+		rest := data[i:]
+		switch {
+		case bytes.HasPrefix(rest, []byte(escapecodes.BracketedPasteStart)):
+			content := rest[len(escapecodes.BracketedPasteStart):]
+			end := bytes.Index(content, []byte(escapecodes.BracketedPasteEnd))
+			if end < 0 {
+				return h.keepPending(keys, rest), sequences
+			}
+			sequences = append(sequences, &HostPaste{Data: bytes.Clone(content[:end])})
+			i += len(escapecodes.BracketedPasteStart) + end + len(escapecodes.BracketedPasteEnd)
+		case bytes.HasPrefix(rest, []byte(escapecodes.OSC52)):
+			content := rest[len(escapecodes.OSC52):]
+			end, terminatorLength := findStringTerminator(content)
+			if end < 0 {
+				return h.keepPending(keys, rest), sequences
+			}
+			if reply := parseOSC52Reply(content[:end]); reply != nil {
+				sequences = append(sequences, reply)
+			}
+			i += len(escapecodes.OSC52) + end + terminatorLength
+		case bytes.HasPrefix(rest, []byte(escapecodes.FocusIn)):
+			sequences = append(sequences, &HostFocus{Focused: true})
+			i += len(escapecodes.FocusIn)
+		case bytes.HasPrefix(rest, []byte(escapecodes.FocusOut)):
+			sequences = append(sequences, &HostFocus{Focused: false})
+			i += len(escapecodes.FocusOut)
+		case isPartialHostSequenceStart(rest):
+			h.partial = true
+			return h.keepPending(keys, rest), sequences
+		default:
+			keys = append(keys, data[i])
+			i++
+		}
+	}
+	return keys, sequences
+}
+
+func (h *HostInputSplitter) keepPending(keys []byte, rest []byte) []byte {
+	if len(rest) > MaxHostInputSequenceSize {
+		return append(keys, rest...)
+	}
+	h.pending = bytes.Clone(rest)
+	return keys
+}
+
+/**
+ * Is Split holding back the start of a sequence,
+ * which Flush would give up on.
+ */
+func (h *HostInputSplitter) HasPartial() bool {
+	return h.partial && len(h.pending) > 0
+}
+
+/**
+ * No more input came after the start of a sequence,
+ * it was keys after all. Returns them.
+ */
+func (h *HostInputSplitter) Flush() []byte {
+	if !h.HasPartial() {
+		return nil
+	}
+	keys := h.pending
+	h.pending = nil
+	h.partial = false
+	return keys
+}
+
+/**
+ * A lone escape is the escape key, so only wait for
+ * more input if we have seen more than the first two
+ * bytes of a sequence start.
+ */
+func isPartialHostSequenceStart(rest []byte) bool {
+	if len(rest) < 3 {
+		return false
+	}
+	for _, start := range hostSequenceStarts {
+		if len(rest) < len(start) && strings.HasPrefix(start, string(rest)) {
+			return true
+		}
+	}
+	return false
+}
+
+func findStringTerminator(content []byte) (end int, terminatorLength int) {
+	bel := bytes.Index(content, []byte(escapecodes.BEL))
+	st := bytes.Index(content, []byte(escapecodes.ST))
+	switch {
+	case bel < 0 && st < 0:
+		return -1, 0
+	case st < 0 || (bel >= 0 && bel < st):
+		return bel, len(escapecodes.BEL)
+	default:
+		return st, len(escapecodes.ST)
+	}
+}
+
+/**
+ * content looks like <selection>;<base64 data>
+ */
+func parseOSC52Reply(content []byte) *HostClipboardReply {
+	name, encoded, ok := bytes.Cut(content, []byte(";"))
+	if !ok || string(encoded) == "?" {
+		return nil
+	}
+	data, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil
+	}
+	return &HostClipboardReply{Name: string(name), Data: data}
+}
+
+func OSC52Copy(name string, data []byte) string {
+	return fmt.Sprintf("%s%s;%s%s", escapecodes.OSC52, name, base64.StdEncoding.EncodeToString(data), escapecodes.BEL)
+}
+
+func OSC52Query(name string) string {
+	return fmt.Sprintf("%s%s;?%s", escapecodes.OSC52, name, escapecodes.BEL)
+}
+
+func (tw *TerminalWindow) ProcessHostSequences(sequences []HostInputSequence) {
+	for _, sequence := range sequences {
+		switch seq := sequence.(type) {
+		case *HostPaste:
+			if len(seq.Data) == 0 {
+				continue
+			}
+			/**
+			 * Drop the paste where the pointer is, so clients
+			 * that take drops (like file pickers) get the paths.
+			 * Anything else gets it typed, like before.
+			 */
+			data := seq.Data
+			unlock := tw.LockClients()
+			dropped := wayland.DragAndDrop.DropFromHost(wayland.MakeHostSelectionSource(data), func() {
+				tw.RefusedPastes <- data
+			})
+			unlock()
+			if !dropped {
+				tw.ProcessKeys(data)
+			}
+		case *HostClipboardReply:
+			if len(seq.Data) == 0 {
+				continue
+			}
+			switch seq.Name {
+			case "c":
+				wayland.Clipboard.SetHostSelection(seq.Data)
+			case "p":
+				if tw.Args != nil && tw.Args.HostPrimarySelection {
+					wayland.PrimarySelection.SetHostSelection(seq.Data)
+				}
+			}
+		case *HostFocus:
+			if !seq.Focused {
+				/**
+				 * The terminal won't report the release
+				 * of a button held while it is unfocused
+				 */
+				unlock := tw.LockClients()
+				tw.PressedMouseButton = nil
+				wayland.Pointer.ReleaseAll(uint32(time.Now().UnixMilli()))
+				unlock()
+				continue
+			}
+			if tw.Args == nil || !tw.Args.ReadHostClipboard {
+				continue
+			}
+			tw.TerminalOutput <- OSC52Query("c")
+			if tw.Args.HostPrimarySelection {
+				tw.TerminalOutput <- OSC52Query("p")
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package termeverything
+
+import (
+	"fmt"
+	"strings"
+)
+
+/**
+ * A key with modifiers, like the command mode prefix
+ */
+type KeyChord struct {
+	Key       Linux_Event_Codes
+	Modifiers int
+}
+
+const DefaultPrefixKey = "ctrl+alt+q"
+
+var namedKeys = map[string]Linux_Event_Codes{
+	"space":     KEY_SPACE,
+	"tab":       KEY_TAB,
+	"enter":     KEY_ENTER,
+	"esc":       KEY_ESC,
+	"backspace": KEY_BACKSPACE,
+}
+
+/**
+ * Parse modifiers and a key joined by +, like "ctrl+alt+q"
+ */
+func ParseKeyChord(text string) (KeyChord, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(text)), "+")
+	chord := KeyChord{}
+	for _, modifier := range parts[:len(parts)-1] {
+		switch modifier {
+		case "ctrl", "control":
+			chord.Modifiers |= ModControl
+		case "alt", "meta":
+			chord.Modifiers |= ModAlt
+		case "shift":
+			chord.Modifiers |= ModShift
+		default:
+			return chord, fmt.Errorf("unknown modifier %q in %q", modifier, text)
+		}
+	}
+	key := parts[len(parts)-1]
+	switch {
+	case len(key) == 1 && key[0] >= 'a' && key[0] <= 'z':
+		chord.Key = alphaKeys[key[0]-'a']
+	case len(key) == 1 && key[0] >= '0' && key[0] <= '9':
+		chord.Key = numericKeys[key[0]-'0']
+	default:
+		named, ok := namedKeys[key]
+		if !ok {
+			return chord, fmt.Errorf("unknown key %q in %q", key, text)
+		}
+		chord.Key = named
+	}
+	return chord, nil
+}
+
+func (k KeyChord) Matches(code *KeyCode) bool {
+	return code.KeyCode == k.Key && code.Modifiers == k.Modifiers
+}
+
+/**
+ * Like "Ctrl-Alt-Q", for the status line
+ */
+func (k KeyChord) String() string {
+	var sb strings.Builder
+	if k.Modifiers&ModControl != 0 {
+		sb.WriteString("Ctrl-")
+	}
+	if k.Modifiers&ModAlt != 0 {
+		sb.WriteString("Alt-")
+	}
+	if k.Modifiers&ModShift != 0 {
+		sb.WriteString("Shift-")
+	}
+	for i, key := range alphaKeys {
+		if key == k.Key {
+			sb.WriteByte(byte('A' + i))
+			return sb.String()
+		}
+	}
+	for i, key := range numericKeys {
+		if key == k.Key {
+			sb.WriteByte(byte('0' + i))
+			return sb.String()
+		}
+	}
+	for name, key := range namedKeys {
+		if key == k.Key {
+			sb.WriteString(strings.ToUpper(name[:1]) + name[1:])
+			break
+		}
+	}
+	return sb.String()
+}
@@ -2,6 +2,7 @@ package termeverything
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"os/exec"
 	"strings"
@@ -11,7 +12,19 @@ import (
 
 func MainLoop() {
 	args := ParseArgs()
+	prefixKey, err := ParseKeyChord(args.PrefixKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Bad --prefix-key: %v\n", err)
+		os.Exit(1)
+	}
 	SetVirtualMonitorSize(args.VirtualMonitorSize)
+	wayland.PrimarySelection.MirrorToHost = args.HostPrimarySelection
+	wayland.FloatingWindows = args.FloatingWindows
+	for _, name := range strings.Split(args.SandboxHideGlobals, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			wayland.SandboxHiddenGlobals[name] = true
+		}
+	}
 	listener, err := wayland.MakeSocketListener(&args)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to create socket listener: %v\n", err)
@@ -27,13 +40,17 @@ func MainLoop() {
 		displaySize,
 		&args,
 	)
+	terminalWindow.PrefixKey = prefixKey
+	terminalWindow.Display.HideStatusBar.Store(args.HideStatusBar)
 
 	terminanDrawLoop := MakeTerminalDrawLoop(
 		displaySize,
-		args.HideStatusBar,
+		terminalWindow.Display,
+		prefixKey,
 		len(args.Positionals) > 0,
 		terminalWindow.SharedRenderedScreenSize,
 		terminalWindow.FrameEvents,
+		terminalWindow.TerminalOutput,
 		&args,
 	)
 
@@ -42,16 +59,46 @@ func MainLoop() {
 	go terminanDrawLoop.MainLoop()
 
 	done := make(chan struct{})
+	/**
+	 * Xwayland's connection comes in separately,
+	 * so it can see the globals only it should.
+	 */
+	xwaylandConnections := make(chan *net.UnixConn, 1)
 	go func() {
 		for {
-			conn := <-listener.OnConnection
-			client := wayland.MakeClient(conn)
+			var client *wayland.Client
+			select {
+			case conn := <-listener.OnConnection:
+				client = wayland.MakeClient(conn)
+			case conn := <-xwaylandConnections:
+				client = wayland.MakeClient(conn)
+				client.Xwayland = true
+			case sandboxed := <-wayland.SandboxedConnections:
+				client = wayland.MakeClient(sandboxed.Conn)
+				client.SecurityContext = sandboxed.Context
+			}
 			terminalWindow.GetClients <- client
 			terminanDrawLoop.GetClients <- client
-			go client.MainLoop()
+			go func() {
+				client.MainLoop()
+				/**
+				 * Drop it now, the loops would only notice
+				 * when they next lock their clients.
+				 */
+				terminanDrawLoop.RemoveClients <- client
+				terminalWindow.RemoveClients <- client
+			}()
 		}
 	}()
 
+	if options := GetXwaylandOptions(&args); options != nil {
+		xwayland := MakeXwaylandSupervisor(*options, args.Shell, xwaylandConnections)
+		terminalWindow.Xwayland = xwayland
+		if err := xwayland.Start(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to start Xwayland: %v\n", err)
+		}
+	}
+
 	if len(args.Positionals) > 0 {
 		cmdStr := strings.Join(args.Positionals, " ")
 		shell := args.Shell
@@ -70,6 +117,11 @@ func MainLoop() {
 			filtered = append(filtered, e)
 		}
 		filtered = append(filtered, fmt.Sprintf("WAYLAND_DISPLAY=%s", listener.WaylandDisplayName))
+		if terminalWindow.Xwayland != nil {
+			if display := terminalWindow.Xwayland.GetDisplay(); display != "" {
+				filtered = append(filtered, fmt.Sprintf("DISPLAY=%s", display))
+			}
+		}
 		if !args.SupportOldApps {
 			filtered = append(filtered, "XDG_SESSION_TYPE=wayland")
 		}
@@ -90,8 +142,6 @@ func MainLoop() {
 
 	<-done
 
-	//TODO start xwaylnd_if_neccessary
-
 	// // Wait for SigInt, TODO something different
 	// sig := make(chan os.Signal, 1)
 	// signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
@@ -16,6 +16,12 @@ var licensesFile string
 
 const version = "0.7.6"
 
+/**
+ * The clipboards, so sandboxed apps can't read
+ * what other apps copy.
+ */
+const DefaultSandboxHiddenGlobals = "wl_data_device_manager,zwp_primary_selection_device_manager_v1"
+
 type CommandLineArgs struct {
 	WaylandDisplayNameArg string
 	SupportOldApps        bool
@@ -27,6 +33,11 @@ type CommandLineArgs struct {
 	DebugLog              bool
 	ReverseScroll         bool
 	MaxFrameRate          string
+	ReadHostClipboard     bool
+	HostPrimarySelection  bool
+	SandboxHideGlobals    string
+	FloatingWindows       bool
+	PrefixKey             string
 	Positionals           []string
 }
 
@@ -51,6 +62,11 @@ func ParseArgs() CommandLineArgs {
 	licensesFlag := flag.Bool("licenses", false, "")
 	flag.BoolVar(&args.ReverseScroll, "reverse-scroll", false, "")
 	flag.StringVar(&args.MaxFrameRate, "max-frame-rate", "", "")
+	flag.BoolVar(&args.ReadHostClipboard, "read-host-clipboard", false, "")
+	flag.BoolVar(&args.HostPrimarySelection, "host-primary-selection", false, "")
+	flag.StringVar(&args.SandboxHideGlobals, "sandbox-hide-globals", DefaultSandboxHiddenGlobals, "")
+	flag.BoolVar(&args.FloatingWindows, "floating-windows", false, "")
+	flag.StringVar(&args.PrefixKey, "prefix-key", DefaultPrefixKey, "")
 
 	flag.Parse()
 
@@ -0,0 +1,41 @@
+package termeverything
+
+import (
+	"github.com/mmulet/term.everything/wayland"
+)
+
+/**
+ * While a popup (a menu, a dropdown) has a grab, Esc
+ * closes the topmost one and a click outside all of them
+ * closes them all, instead of going to a window.
+ * Returns true if it used code. The clients must be locked.
+ */
+func (tw *TerminalWindow) PopupGrabInput(code XkbdCode) bool {
+	if !wayland.PopupGrab.IsActive() {
+		return false
+	}
+	switch c := code.(type) {
+	case *KeyCode:
+		if c.KeyCode != KEY_ESC || c.Modifiers != 0 {
+			return false
+		}
+		wayland.PopupGrab.DismissTopmost()
+	case *PointerButtonPress:
+		if wayland.DragAndDrop.IsActive() || wayland.MoveResize.IsActive() {
+			return false
+		}
+		hit := wayland.FindSurfaceAt(tw.Clients, wayland.Pointer.WindowX, wayland.Pointer.WindowY)
+		if hit != nil && wayland.PopupGrab.Contains(hit.Client, hit.SurfaceID) {
+			return false
+		}
+		wayland.PopupGrab.DismissAll()
+		/**
+		 * The click only closes the popups
+		 */
+		tw.SwallowButtonRelease = true
+	default:
+		return false
+	}
+	wayland.Pointer.Refresh(tw.Clients)
+	return true
+}
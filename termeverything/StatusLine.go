@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/mmulet/term.everything/escapecodes"
 	"github.com/mmulet/term.everything/framebuffertoansi"
@@ -51,6 +52,9 @@ type Status_Line struct {
 	b       map[string]*StatusLineButton
 	Sponsor *StatusLineButton
 	Bugs    *StatusLineButton
+
+	Display   *DisplaySettings
+	PrefixKey KeyChord
 }
 
 func (s *Status_Line) UpdateMousePosition(code *PointerMove) {
@@ -84,19 +88,19 @@ func (s *Status_Line) PostFrame(delta_time float64) {
 	}
 }
 
-func MakeStatusLine() *Status_Line {
+func MakeStatusLine(display *DisplaySettings, prefixKey KeyChord) *Status_Line {
 	sl := &Status_Line{
 		ShowStatusLine: true,
+		Display:        display,
+		PrefixKey:      prefixKey,
 	}
 	sl.TerminalMousePosition.x = -1
 	sl.TerminalMousePosition.y = -1
 
-	escape := KEY_ESC
 	sl.b = map[string]*StatusLineButton{
-		"escape": &StatusLineButton{
+		"quit": &StatusLineButton{
 			Button: LineButton{
-				Keycode: &escape,
-				String:  "[ESC] to quit",
+				String: "[" + prefixKey.String() + " q] to quit",
 				Callback: func() {
 					GlobalExitChan <- 0
 				},
@@ -136,19 +140,32 @@ func MakeStatusLine() *Status_Line {
 	return sl
 }
 
-func (s *Status_Line) Draw(delta_time float64, app_title *string, keys_pressed_this_frame map[Linux_Event_Codes]bool) string {
+func (s *Status_Line) Draw(delta_time float64, tabs []WindowTab, keys_pressed_this_frame map[Linux_Event_Codes]bool) string {
 	if !s.ShowStatusLine {
 		return ""
 	}
 
-	text := s.Line(keys_pressed_this_frame,
-		s.b["escape"], &StatusLineText{" "},
+	if s.Display.CommandMode.Load() {
+		return s.Fit("[" + s.PrefixKey.String() + "] " + CommandModeHelp)
+	}
+
+	parts := []StatusLineTextOrButton{
+		s.b["quit"], &StatusLineText{" "},
 		s.Sponsor, &StatusLineText{" | "},
-		s.ChooseAppTitle(app_title), &StatusLineText{" | "},
-	)
+	}
+	parts = append(parts, s.WindowTabParts(tabs)...)
+	parts = append(parts, &StatusLineText{" | "})
+	text := s.Line(keys_pressed_this_frame, parts...)
 
 	s.TextLoopTime += delta_time
 
+	return s.Fit(text)
+}
+
+/**
+ * Cut text off at the terminal width
+ */
+func (s *Status_Line) Fit(text string) string {
 	width := 0
 	if winsize, err := framebuffertoansi.GetWinsize(os.Stdout.Fd()); err == nil {
 		width = int(winsize.Col)
@@ -220,11 +237,79 @@ func getEnvOr(k, def string) string {
 	return v
 }
 
-func (s *Status_Line) ChooseAppTitle(appTitle *string) StatusLineTextOrButton {
-	if appTitle == nil || *appTitle == "" {
-		return s.Bugs
+/**
+ * A window in the status line
+ */
+type WindowTab struct {
+	Title     string
+	Focused   bool
+	Minimized bool
+	/**
+	 * Restore, raise and focus the window,
+	 * called with the clients locked.
+	 */
+	Show func()
+	/**
+	 * nil for X windows
+	 */
+	Minimize func()
+}
+
+/**
+ * Longer titles are cut off, so a few tabs fit
+ */
+const MaxWindowTabTitleLength = 24
+
+/**
+ * A button for each window, the focused one in brackets
+ * and minimized ones in parentheses. The bug report
+ * button when there are no windows.
+ */
+func (s *Status_Line) WindowTabParts(tabs []WindowTab) []StatusLineTextOrButton {
+	if len(tabs) == 0 {
+		return []StatusLineTextOrButton{s.Bugs}
 	}
-	return &StatusLineText{*appTitle}
+	parts := make([]StatusLineTextOrButton, 0, len(tabs)*2)
+	for i, tab := range tabs {
+		if i > 0 {
+			parts = append(parts, &StatusLineText{" "})
+		}
+		title := []rune(PrintableTitle(tab.Title))
+		if len(title) > MaxWindowTabTitleLength {
+			title = append(title[:MaxWindowTabTitleLength-1], '…')
+		}
+		if len(title) == 0 {
+			title = []rune("untitled")
+		}
+		label := " " + string(title) + " "
+		switch {
+		case tab.Focused:
+			label = "[" + string(title) + "]"
+		case tab.Minimized:
+			label = "(" + string(title) + ")"
+		}
+		parts = append(parts, &StatusLineButton{
+			Button: LineButton{
+				String:   label,
+				Callback: tab.Show,
+			},
+		})
+	}
+	return parts
+}
+
+/**
+ * Titles come from clients, and go straight to the
+ * terminal. Control characters (C0, DEL and C1) could
+ * start escape sequences, so they are replaced.
+ */
+func PrintableTitle(title string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || (r >= 0x7f && r <= 0x9f) {
+			return utf8.RuneError
+		}
+		return r
+	}, title)
 }
 
 func (s *Status_Line) KeyboardKeyHitButton(button LineButton, keys_pressed_this_frame map[Linux_Event_Codes]bool) LineButton {
@@ -248,7 +333,7 @@ func (s *Status_Line) Line(keys_pressed_this_frame map[Linux_Event_Codes]bool, p
 		switch it := v.(type) {
 		case *StatusLineText:
 			out.WriteString(it.String)
-			position += len(it.String)
+			position += utf8.RuneCountInString(it.String)
 		case *StatusLineButton:
 			btn := s.KeyboardKeyHitButton(it.Button, keys_pressed_this_frame)
 			nextString := btn.String
@@ -260,7 +345,7 @@ func (s *Status_Line) Line(keys_pressed_this_frame map[Linux_Event_Codes]bool, p
 			already_called_callback := false
 			if s.TerminalMousePosition.y == 0 &&
 				int(s.TerminalMousePosition.x) >= position &&
-				int(s.TerminalMousePosition.x) < position+len(nextString) {
+				int(s.TerminalMousePosition.x) < position+utf8.RuneCountInString(nextString) {
 				out.WriteString(escapecodes.BgWhite + escapecodes.FgBlack + nextString + escapecodes.Reset)
 				if s.TerminalMouseButton.pressed &&
 					s.TerminalMouseButton.frame_held_time == 0 {
@@ -271,7 +356,7 @@ func (s *Status_Line) Line(keys_pressed_this_frame map[Linux_Event_Codes]bool, p
 			} else {
 				out.WriteString(nextString)
 			}
-			position += len(nextString)
+			position += utf8.RuneCountInString(nextString)
 		}
 	}
 	return out.String()
@@ -30,7 +30,7 @@ type TerminalDrawLoop struct {
 
 	TimeOfLastTerminalDraw *float64
 
-	HideStatusBar bool
+	Display *DisplaySettings
 
 	/**
 	 * Don't draw until at least MinTerminalTimeSeconds has passed
@@ -49,23 +49,35 @@ type TerminalDrawLoop struct {
 
 	FrameEvents chan XkbdCode
 
+	TerminalOutput chan string
+
 	TimeOfStartOfLastFrame *float64
 
 	DesiredFrameTimeSeconds float64
 
 	StatusLine *Status_Line
 
-	GetClients      chan *wayland.Client
-	FirstDrawDone   bool
-	LastDrawSize    framebuffertoansi.WinSize
+	GetClients chan *wayland.Client
+	/**
+	 * Clients that disconnected
+	 */
+	RemoveClients chan *wayland.Client
+	FirstDrawDone bool
+	LastDrawSize  framebuffertoansi.WinSize
+	/**
+	 * The status line as it was last drawn
+	 */
+	LastStatusLine  string
 	FrameInputState FrameInputState
 }
 
 func MakeTerminalDrawLoop(desktop_size wayland.Size,
-	hide_status_bar bool,
+	display *DisplaySettings,
+	prefixKey KeyChord,
 	willShowAppRightAtStartup bool,
 	sharedRenderedScreenSize *RenderedScreenSize,
 	frameEvents chan XkbdCode,
+	terminalOutput chan string,
 	args *CommandLineArgs,
 
 ) *TerminalDrawLoop {
@@ -74,7 +86,7 @@ func MakeTerminalDrawLoop(desktop_size wayland.Size,
 		TimeOfLastTerminalDraw:   nil,
 		MinTerminalTimeSeconds:   nil,
 		SharedRenderedScreenSize: sharedRenderedScreenSize,
-		HideStatusBar:            hide_status_bar,
+		Display:                  display,
 		DrawState: framebuffertoansi.MakeDrawState(
 			DisplayServerType() == DisplayServerTypeX11,
 		),
@@ -87,9 +99,11 @@ func MakeTerminalDrawLoop(desktop_size wayland.Size,
 
 		TimeOfStartOfLastFrame:  nil,
 		DesiredFrameTimeSeconds: 0.016, // ~60 FPS
-		StatusLine:              MakeStatusLine(),
+		StatusLine:              MakeStatusLine(display, prefixKey),
 		FrameEvents:             frameEvents,
+		TerminalOutput:          terminalOutput,
 		GetClients:              make(chan *wayland.Client, 32),
+		RemoveClients:           make(chan *wayland.Client, 32),
 		FrameInputState:         MakeFrameInputState(),
 	}
 	if args != nil && args.MaxFrameRate != "" {
@@ -102,20 +116,64 @@ func MakeTerminalDrawLoop(desktop_size wayland.Size,
 	return tw
 }
 
-func (tw *TerminalDrawLoop) GetAppTitle() *string {
-	for _, s := range tw.Clients {
-		for topLevelID := range s.TopLevelSurfaces() {
-			top_level := wayland.GetXdgToplevelObject(s, topLevelID)
-			if top_level == nil {
+/**
+ * Every window, for the status line. Clients in the order
+ * they connected, their windows in the order they were made.
+ * The clients must be locked.
+ */
+func WindowTabs(clients []*wayland.Client) []WindowTab {
+	tabs := make([]WindowTab, 0)
+	for _, s := range clients {
+		ids := make([]protocols.ObjectID[protocols.XdgToplevel], 0)
+		for id, isTopLevel := range s.TopLevelSurfaces() {
+			if isTopLevel {
+				ids = append(ids, id)
+			}
+		}
+		slices.Sort(ids)
+		for _, id := range ids {
+			top := wayland.GetXdgToplevelObject(s, id)
+			surface_id := wayland.GetSurfaceIDFromRole(s, id)
+			if top == nil || surface_id == nil {
 				continue
 			}
-			return top_level.Title
+			title := top.AppID
+			if top.Title != nil && *top.Title != "" {
+				title = *top.Title
+			}
+			tabs = append(tabs, WindowTab{
+				Title:     title,
+				Focused:   wayland.KeyboardFocus.IsFocused(s, *surface_id),
+				Minimized: top.Minimized,
+				Show: func() {
+					top.Restore(s, id)
+				},
+				Minimize: func() {
+					top.XdgToplevel_set_minimized(s, id)
+				},
+			})
+		}
+	}
+	for _, window := range wayland.XWindows.Listed() {
+		surface := *window.Surface
+		title := ""
+		if window.Title != nil {
+			title = *window.Title
 		}
+		tabs = append(tabs, WindowTab{
+			Title:   title,
+			Focused: wayland.KeyboardFocus.IsFocused(surface.Client, surface.SurfaceID),
+			Show: func() {
+				if wayland.KeyboardFocus.Focus(surface.Client, surface.SurfaceID) {
+					wayland.XWindows.Activate(surface.Client, surface.SurfaceID)
+				}
+			},
+		})
 	}
-	return nil
+	return tabs
 }
 
-func (tw *TerminalDrawLoop) DrawToTerminal(status_line string) {
+func (tw *TerminalDrawLoop) DrawToTerminal(status_line string, overlay string) {
 
 	// if protocols.DebugRequests {
 	// 	fmt.Println("Debugging!!!")
@@ -124,7 +182,10 @@ func (tw *TerminalDrawLoop) DrawToTerminal(status_line string) {
 	// }
 
 	var statusLine *string
-	if !tw.HideStatusBar {
+	/**
+	 * Command mode shows its keys even when the status bar is hidden
+	 */
+	if !tw.Display.HideStatusBar.Load() || tw.Display.CommandMode.Load() {
 		statusLine = &status_line
 	}
 
@@ -133,6 +194,7 @@ func (tw *TerminalDrawLoop) DrawToTerminal(status_line string) {
 		tw.VirtualMonitorSize.Width,
 		tw.VirtualMonitorSize.Height,
 		statusLine,
+		overlay,
 	)
 	tw.SharedRenderedScreenSize.WidthCells = &widthCells
 	tw.SharedRenderedScreenSize.HeightCells = &heightCells
@@ -159,9 +221,14 @@ func (tw *TerminalDrawLoop) MainLoop() {
 					tw.StatusLine.HandleTerminalMousePress(false)
 				case *PointerWheel:
 				}
+			case output := <-tw.TerminalOutput:
+				os.Stdout.WriteString(output)
+			case selection := <-wayland.HostSelectionCopies:
+				os.Stdout.WriteString(OSC52Copy(selection.Name, selection.Data))
 			case client := <-tw.GetClients:
-				//TODO removing clients
 				tw.Clients = append(tw.Clients, client)
+			case client := <-tw.RemoveClients:
+				tw.Clients = slices.DeleteFunc(tw.Clients, func(s *wayland.Client) bool { return s == client })
 			case <-timeout:
 				goto KeyReadLoop
 			}
@@ -184,23 +251,10 @@ func (tw *TerminalDrawLoop) DrawClients() {
 	} else {
 		delta_time = tw.DesiredFrameTimeSeconds
 	}
-	num_draw_requests := 0
-	for _, s := range tw.Clients {
-		for {
-			select {
-			case callback_id := <-s.FrameDrawRequests:
-				protocols.WlCallback_done(s, callback_id, uint32(time.Now().UnixMilli()))
-				num_draw_requests++
-			default:
-				goto DoneCallbacks
-			}
-		}
-	DoneCallbacks:
-	}
 	clients_to_delete := make([]int, 0)
 	for i, s := range tw.Clients {
 		s.Access.Lock()
-		if s.Status != wayland.ClientStatus_Connected {
+		if s.GetStatus() != wayland.ClientStatus_Connected {
 			s.Access.Unlock()
 			clients_to_delete = append(clients_to_delete, i)
 			continue
@@ -213,8 +267,26 @@ func (tw *TerminalDrawLoop) DrawClients() {
 		tw.Clients = slices.Delete(tw.Clients, index, index+1)
 	}
 
+	/**
+	 * done destroys the callback, so
+	 * the client has to be locked.
+	 */
+	num_draw_requests := 0
+	for _, s := range tw.Clients {
+		for {
+			select {
+			case callback_id := <-s.FrameDrawRequests:
+				protocols.WlCallback_done(s, callback_id, uint32(time.Now().UnixMilli()))
+				num_draw_requests++
+			default:
+				goto DoneCallbacks
+			}
+		}
+	DoneCallbacks:
+	}
+
 	for _, s := range tw.Clients {
-		pointer_surface_id := wayland.Pointer.PointerSurfaceID[s]
+		pointer_surface_id := wayland.Pointer.CursorSurface(s)
 		if pointer_surface_id == nil {
 			continue
 		}
@@ -229,11 +301,25 @@ func (tw *TerminalDrawLoop) DrawClients() {
 	}
 
 	tw.Desktop.DrawClients(tw.Clients)
+	/**
+	 * Windows may have appeared, moved or closed under the pointer
+	 */
+	if !wayland.DragAndDrop.IsActive() {
+		wayland.Pointer.Refresh(tw.Clients)
+	}
 
-	status_line := tw.StatusLine.Draw(delta_time, tw.GetAppTitle(), tw.FrameInputState.KeysPressedThisFrame)
+	status_line := tw.StatusLine.Draw(delta_time, WindowTabs(tw.Clients), tw.FrameInputState.KeysPressedThisFrame)
 
-	if tw.ShouldDrawFrame(start_of_frame, num_draw_requests) {
-		tw.DrawToTerminal(status_line)
+	overlay := WindowMenuOverlay(tw.SharedRenderedScreenSize, tw.VirtualMonitorSize)
+
+	tw.DrawState.SetPixelModeOverride(framebuffertoansi.PixelModes[tw.Display.PixelMode.Load()])
+	changed := overlay != tw.DrawState.LastOverlay ||
+		status_line != tw.LastStatusLine ||
+		tw.DrawState.NeedsClear
+
+	if tw.ShouldDrawFrame(start_of_frame, num_draw_requests, changed) {
+		tw.DrawToTerminal(status_line, overlay)
+		tw.LastStatusLine = status_line
 	}
 
 	// const draw_time = Date.now();
@@ -254,7 +340,11 @@ func (tw *TerminalDrawLoop) ResetFrameState() {
 	clear(tw.FrameInputState.KeysPressedThisFrame)
 }
 
-func (tw *TerminalDrawLoop) ShouldDrawFrame(start_of_frame float64, num_draw_requests int) (should_draw bool) {
+/**
+ * changed is true when something other than
+ * the desktop needs to be redrawn.
+ */
+func (tw *TerminalDrawLoop) ShouldDrawFrame(start_of_frame float64, num_draw_requests int, changed bool) (should_draw bool) {
 	defer func() {
 		if should_draw {
 			tw.FirstDrawDone = true
@@ -282,6 +372,9 @@ func (tw *TerminalDrawLoop) ShouldDrawFrame(start_of_frame float64, num_draw_req
 			return true
 		}
 	}
+	if changed {
+		return true
+	}
 	if num_draw_requests == 0 {
 		return tw.FrameInputState.MouseMoveThisFrame || !tw.FirstDrawDone
 	}
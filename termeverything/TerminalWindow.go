@@ -32,23 +32,65 @@ type TerminalWindow struct {
 	SocketListener     *wayland.SocketListener
 	VirtualMonitorSize wayland.Size
 
+	/**
+	 * Capture after the prefix key, see CommandModeInput
+	 */
 	Mode WindowMode
 
+	PrefixKey KeyChord
+
+	/**
+	 * Shared with the draw loop
+	 */
+	Display *DisplaySettings
+
 	FrameEvents chan XkbdCode
 
 	Args *CommandLineArgs
 
-	KeySerial uint32
-
 	PressedMouseButton *LINUX_BUTTON_CODES
 
+	/**
+	 * The terminal cell the mouse was last seen in
+	 */
+	MouseCol int
+	MouseRow int
+
+	/**
+	 * A button press went to the window menu, or closed
+	 * the grabbed popups, so its release shouldn't go to a window.
+	 */
+	SwallowButtonRelease bool
+
 	Clients []*wayland.Client
 
 	GetClients chan *wayland.Client
+	/**
+	 * Clients that disconnected
+	 */
+	RemoveClients chan *wayland.Client
 
 	SharedRenderedScreenSize *RenderedScreenSize
 
 	RestoreTerminalMode func() error
+
+	HostInput HostInputSplitter
+
+	/**
+	 * Pastes no client took as a drop, to be typed instead
+	 */
+	RefusedPastes chan []byte
+
+	/**
+	 * Escape codes that need to go to the terminal, written
+	 * by the draw loop so they don't land in the middle of a frame.
+	 */
+	TerminalOutput chan string
+
+	/**
+	 * nil unless Xwayland was asked for
+	 */
+	Xwayland *XwaylandSupervisor
 }
 
 func MakeTerminalWindow(
@@ -69,19 +111,30 @@ func MakeTerminalWindow(
 		Mode:                     WindowMode_Passthrough,
 		FrameEvents:              make(chan XkbdCode, 8192),
 		Args:                     args,
-		KeySerial:                0,
 		PressedMouseButton:       nil,
 		SharedRenderedScreenSize: &RenderedScreenSize{},
+		Display:                  &DisplaySettings{},
 		Clients:                  make([]*wayland.Client, 0),
 		// RestoreTerminalMode:      func() error { return nil },
 		RestoreTerminalMode: restoreTerminalMode,
 		GetClients:          make(chan *wayland.Client, 32),
+		RemoveClients:       make(chan *wayland.Client, 32),
+		TerminalOutput:      make(chan string, 32),
+		RefusedPastes:       make(chan []byte, 8),
 	}
 
 	if !protocols.DebugRequests {
 		os.Stdout.WriteString(escapecodes.EnableAlternativeScreenBuffer)
 		os.Stdout.WriteString(escapecodes.EnableMouseTracking)
 		os.Stdout.WriteString(escapecodes.EnableSGR)
+		os.Stdout.WriteString(escapecodes.EnableBracketedPaste)
+		if args != nil && args.ReadHostClipboard {
+			os.Stdout.WriteString(escapecodes.EnableFocusReporting)
+			os.Stdout.WriteString(OSC52Query("c"))
+			if args.HostPrimarySelection {
+				os.Stdout.WriteString(OSC52Query("p"))
+			}
+		}
 
 		os.Stdout.WriteString(escapecodes.HideCursor)
 	}
@@ -114,6 +167,9 @@ func (tw *TerminalWindow) OnExit() {
 		}
 	}
 	tw.RestoreTerminalMode()
+	if tw.Xwayland != nil {
+		tw.Xwayland.Stop()
+	}
 
 	os.Stdout.WriteString(escapecodes.DisableAlternativeScreenBuffer)
 	os.Stdout.WriteString(escapecodes.ShowCursor)
@@ -121,101 +177,181 @@ func (tw *TerminalWindow) OnExit() {
 	// TODO re-enable if enabled above
 	// os.Stdout.WriteString(escapecodes.DisableNormalMouseTracking)
 	os.Stdout.WriteString(escapecodes.DisableMouseTracking)
+	os.Stdout.WriteString(escapecodes.DisableBracketedPaste)
+	if tw.Args != nil && tw.Args.ReadHostClipboard {
+		os.Stdout.WriteString(escapecodes.DisableFocusReporting)
+	}
 
 }
 
 func (tw *TerminalWindow) InputLoop() {
-	buf := make([]byte, 4096)
+	chunks := make(chan []byte)
+	go ReadStdin(chunks)
+	var flush <-chan time.Time
 	for {
+		/**
+		 * Clients come and go while nothing is typed,
+		 * so these can't wait for input.
+		 */
+		select {
+		case client := <-tw.GetClients:
+			tw.Clients = append(tw.Clients, client)
+		case client := <-tw.RemoveClients:
+			tw.Clients = slices.DeleteFunc(tw.Clients, func(s *wayland.Client) bool { return s == client })
+		case chunk, ok := <-chunks:
+			if !ok {
+				return
+			}
+			tw.ProcessInput(chunk)
+			flush = nil
+			if tw.HostInput.HasPartial() {
+				flush = time.After(PartialHostSequenceTimeout)
+			}
+		case <-flush:
+			flush = nil
+			tw.ProcessKeys(tw.HostInput.Flush())
+		case paste := <-tw.RefusedPastes:
+			tw.ProcessKeys(paste)
+		}
+	}
+}
+
+func (tw *TerminalWindow) ProcessInput(chunk []byte) {
+	keys, sequences := tw.HostInput.Split(chunk)
+	tw.ProcessHostSequences(sequences)
+	tw.ProcessKeys(keys)
+}
 
+func (tw *TerminalWindow) ProcessKeys(keys []byte) {
+	if len(keys) == 0 {
+		return
+	}
+	codes := ConvertKeycodeToXbdCode(keys)
+	tw.ProcessCodes(codes)
+}
+
+/**
+ * Send everything read from stdin to chunks,
+ * closing it when stdin fails.
+ */
+func ReadStdin(chunks chan<- []byte) {
+	defer close(chunks)
+	for {
+		buf := make([]byte, 4096)
 		n, err := os.Stdin.Read(buf)
 
 		if err != nil || n == 0 {
-			fmt.Printf("Error reading stdin: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
 			return
 		}
-		chunk := buf[:n]
-		for {
-			select {
-			case client := <-tw.GetClients:
-				//TODO removing client
-				tw.Clients = append(tw.Clients, client)
-			default:
-				goto GotData
-			}
-		}
-	GotData:
-		codes := ConvertKeycodeToXbdCode(chunk)
-		tw.ProcessCodes(codes)
+		chunks <- buf[:n]
 	}
 }
 
-func (tw *TerminalWindow) ProcessCodes(codes []XkbdCode) {
+/**
+ * Lock every connected client, and drop the ones
+ * that have disconnected. Call unlock when done.
+ */
+func (tw *TerminalWindow) LockClients() (unlock func()) {
 	clients_to_delete := make([]int, 0)
+	locked := make([]*wayland.Client, 0, len(tw.Clients))
 	for i, s := range tw.Clients {
 		s.Access.Lock()
-		if s.Status != wayland.ClientStatus_Connected {
+		if s.GetStatus() != wayland.ClientStatus_Connected {
 			s.Access.Unlock()
 			clients_to_delete = append(clients_to_delete, i)
 			continue
-		} else {
-			defer s.Access.Unlock()
 		}
+		locked = append(locked, s)
 	}
 	for i := len(clients_to_delete) - 1; i >= 0; i-- {
 		index := clients_to_delete[i]
 		tw.Clients = slices.Delete(tw.Clients, index, index+1)
 	}
+	return func() {
+		for _, s := range locked {
+			s.Access.Unlock()
+		}
+	}
+}
+
+func (tw *TerminalWindow) ProcessCodes(codes []XkbdCode) {
+	unlock := tw.LockClients()
+	defer unlock()
 	now := uint32(time.Now().UnixMilli())
 
+	/**
+	 * While a client grabs the keyboard, keys go
+	 * only to it, not to the status line.
+	 */
+	grabbed := wayland.KeyboardFocus.IsGrabbed()
+
 	for _, code := range codes {
-		tw.FrameEvents <- code
-		new_key_serial := tw.KeySerial
-		tw.KeySerial += 2
-
-		for _, s := range tw.Clients {
-			if keyboard_map := protocols.GetGlobalWlKeyboardBinds(s); keyboard_map != nil {
-				modifiers := code.GetModifiers()
-				for keyboardID := range keyboard_map {
-					protocols.WlKeyboard_modifiers(
-						s,
-						keyboardID,
-						new_key_serial,
-						uint32(modifiers),
-						0, 0, 0,
-					)
-				}
+		_, isKey := code.(*KeyCode)
+		/**
+		 * The grabbing client gets the menu and popup
+		 * keys too. The prefix key still works, and
+		 * focusing another window ends the grab, so
+		 * command mode is the way out of it.
+		 */
+		if isKey && grabbed {
+			if tw.CommandModeInput(code) {
+				continue
+			}
+		} else if tw.WindowMenuInput(code) || tw.CommandModeInput(code) || tw.PopupGrabInput(code) {
+			continue
+		}
+		if move, ok := code.(*PointerMove); ok {
+			tw.MouseCol, tw.MouseRow = move.Col, move.Row
+		}
+		if !isKey || !grabbed {
+			tw.FrameEvents <- code
+		}
+
+		/**
+		 * Only the focused client gets keys
+		 */
+		focused := tw.FocusedClient()
+		if focused != nil {
+			modifiers := code.GetModifiers()
+			for keyboardID := range protocols.GetGlobalWlKeyboardBinds(focused) {
+				protocols.WlKeyboard_modifiers(
+					focused,
+					keyboardID,
+					wayland.NextSerial(),
+					uint32(modifiers),
+					0, 0, 0,
+				)
 			}
 		}
 		switch c := code.(type) {
 		case *KeyCode:
-			for _, s := range tw.Clients {
-				if keyboard_map := protocols.GetGlobalWlKeyboardBinds(s); keyboard_map != nil {
-					for keyboardID := range keyboard_map {
-						protocols.WlKeyboard_key(
-							s,
-							keyboardID,
-							new_key_serial,
-							now,
-							uint32(c.KeyCode),
-							protocols.WlKeyboardKeyState_enum_pressed,
-						)
-						/**
-						 * There is no key up code in
-						 * ANSI escape codes, so
-						 * just say it is released
-						 * instantly
-						 */
-						protocols.WlKeyboard_key(
-							s,
-							keyboardID,
-							new_key_serial+1,
-							now,
-							uint32(c.KeyCode),
-							protocols.WlKeyboardKeyState_enum_released,
-						)
-					}
-				}
+			if focused == nil {
+				break
+			}
+			for keyboardID := range protocols.GetGlobalWlKeyboardBinds(focused) {
+				protocols.WlKeyboard_key(
+					focused,
+					keyboardID,
+					wayland.NextSerial(),
+					now,
+					uint32(c.KeyCode),
+					protocols.WlKeyboardKeyState_enum_pressed,
+				)
+				/**
+				 * There is no key up code in
+				 * ANSI escape codes, so
+				 * just say it is released
+				 * instantly
+				 */
+				protocols.WlKeyboard_key(
+					focused,
+					keyboardID,
+					wayland.NextSerial(),
+					now,
+					uint32(c.KeyCode),
+					protocols.WlKeyboardKeyState_enum_released,
+				)
 			}
 
 		case *PointerMove:
@@ -227,97 +363,63 @@ func (tw *TerminalWindow) ProcessCodes(codes []XkbdCode) {
 				(float32(tw.VirtualMonitorSize.Height) /
 					float32(rows))
 
-			wayland.Pointer.WindowX = x
-			wayland.Pointer.WindowY = y
-
-			for _, s := range tw.Clients {
-				if pointers_map := protocols.GetGlobalWlPointerBinds(s); pointers_map != nil {
-					for pointerID, version := range pointers_map {
-						protocols.WlPointer_motion(
-							s,
-							pointerID,
-							uint32(time.Now().UnixMilli()),
-							x,
-							y,
-						)
-						protocols.WlPointer_frame(
-							s,
-							uint32(version),
-							pointerID,
-						)
-					}
-				}
+			/**
+			 * While dragging, the pointer belongs
+			 * to the drag, not to the clients.
+			 */
+			if wayland.DragAndDrop.IsActive() {
+				wayland.Pointer.WindowX = x
+				wayland.Pointer.WindowY = y
+				wayland.DragAndDrop.Motion(tw.Clients, x, y, now)
+				break
+			}
+			/**
+			 * Same for moving or resizing a window
+			 */
+			if wayland.MoveResize.IsActive() {
+				wayland.Pointer.WindowX = x
+				wayland.Pointer.WindowY = y
+				wayland.MoveResize.Motion(x, y)
+				break
 			}
+			wayland.Pointer.Motion(tw.Clients, x, y, now)
 
 		case *PointerButtonPress:
+			if wayland.DragAndDrop.IsActive() || wayland.MoveResize.IsActive() {
+				break
+			}
+			tw.FocusSurfaceAt(wayland.Pointer.WindowX, wayland.Pointer.WindowY)
 
 			release := tw.GetButtonToReleaseAndUpdatePressedMouseButton(c.Button)
-			for _, s := range tw.Clients {
-
-				if pointer_map := protocols.GetGlobalWlPointerBinds(s); pointer_map != nil {
-					for pointerID, version := range pointer_map {
-						protocols.WlPointer_button(
-							s,
-							pointerID,
-							uint32(time.Now().UnixMilli()),
-							uint32(time.Now().UnixMilli()),
-							uint32(c.Button),
-							protocols.WlPointerButtonState_enum_pressed,
-						)
-						protocols.WlPointer_frame(
-							s,
-							uint32(version),
-							pointerID,
-						)
-						if c.NeedToReleaseOtherButtons && release != nil {
-							protocols.WlPointer_button(
-								s,
-								pointerID,
-								uint32(time.Now().UnixMilli()),
-								uint32(time.Now().UnixMilli()),
-								uint32(*release),
-								protocols.WlPointerButtonState_enum_released,
-							)
-							protocols.WlPointer_frame(
-								s,
-								uint32(version),
-								pointerID,
-							)
-						}
-					}
-				}
+			wayland.Pointer.Button(uint32(c.Button), protocols.WlPointerButtonState_enum_pressed, now)
+			if c.NeedToReleaseOtherButtons && release != nil {
+				wayland.Pointer.Button(uint32(*release), protocols.WlPointerButtonState_enum_released, now)
 			}
 
 		case *PointerButtonRelease:
-			buttonToRelease := c.Button
-			if c.NeedsButtonGuessing {
-				if tw.PressedMouseButton == nil {
-					break
-				}
-				buttonToRelease = *tw.PressedMouseButton
+			if wayland.DragAndDrop.IsActive() {
 				tw.PressedMouseButton = nil
+				wayland.Pointer.EndImplicitGrab()
+				wayland.DragAndDrop.Drop()
+				wayland.Pointer.Refresh(tw.Clients)
+				break
 			}
-
-			for _, s := range tw.Clients {
-
-				if pointer_map := protocols.GetGlobalWlPointerBinds(s); pointer_map != nil {
-					for pointerID, version := range pointer_map {
-						protocols.WlPointer_button(
-							s,
-							pointerID,
-							uint32(time.Now().UnixMilli()),
-							uint32(time.Now().UnixMilli()),
-							uint32(buttonToRelease),
-							protocols.WlPointerButtonState_enum_released,
-						)
-						protocols.WlPointer_frame(
-							s,
-							uint32(version),
-							pointerID,
-						)
-					}
-				}
+			if wayland.MoveResize.IsActive() {
+				tw.PressedMouseButton = nil
+				wayland.Pointer.EndImplicitGrab()
+				wayland.MoveResize.End()
+				wayland.Pointer.Refresh(tw.Clients)
+				break
 			}
+			if c.NeedsButtonGuessing {
+				/**
+				 * Whichever button it was, none are held now
+				 */
+				tw.PressedMouseButton = nil
+				wayland.Pointer.ReleaseAll(now)
+				break
+			}
+			wayland.Pointer.Button(uint32(c.Button), protocols.WlPointerButtonState_enum_released, now)
 
 		case *PointerWheel:
 			_, rows := tw.CurrentTerminalSize()
@@ -327,30 +429,46 @@ func (tw *TerminalWindow) ProcessCodes(codes []XkbdCode) {
 				scale = 1
 			}
 			amount := scale * float32(tw.ScrollDirection(c.Up)) * float32(tw.VirtualMonitorSize.Height) / float32(rows)
-			for _, s := range tw.Clients {
-				if pointer_id := protocols.GetGlobalWlPointerBinds(s); pointer_id != nil {
-					for pointerID, version := range pointer_id {
-						protocols.WlPointer_axis(
-							s,
-							pointerID,
-							uint32(time.Now().UnixMilli()),
-							protocols.WlPointerAxis_enum_vertical_scroll,
-							amount,
-						)
-						protocols.WlPointer_frame(
-							s,
-							uint32(version),
-							pointerID,
-						)
-					}
-				}
-			}
+			wayland.Pointer.Axis(protocols.WlPointerAxis_enum_vertical_scroll, amount, now)
 		default:
 			// literal never_default(code) equivalent: do nothing
 		}
 	}
 }
 
+/**
+ * The focused client, if it is still one of ours.
+ * The clients must be locked.
+ */
+func (tw *TerminalWindow) FocusedClient() *wayland.Client {
+	focused := wayland.KeyboardFocus.FocusedClient()
+	for _, s := range tw.Clients {
+		if protocols.ClientState(s) == focused {
+			return s
+		}
+	}
+	return nil
+}
+
+/**
+ * Click to focus, give keyboard focus to
+ * the window under x, y. The clients must be locked.
+ */
+func (tw *TerminalWindow) FocusSurfaceAt(x, y float32) {
+	hit := wayland.FindSurfaceAt(tw.Clients, x, y)
+	if hit == nil {
+		return
+	}
+	if surface_id := wayland.FocusableSurface(hit.Client, hit.SurfaceID); surface_id != nil {
+		if wayland.KeyboardFocus.Focus(hit.Client, *surface_id) {
+			if top := wayland.ToplevelOfSurface(hit.Client, *surface_id); top != nil {
+				top.Raise()
+			}
+			wayland.XWindows.Activate(hit.Client, *surface_id)
+		}
+	}
+}
+
 func (tw *TerminalWindow) ScrollDirection(code_up bool) float32 {
 	var code float32 = 1.0
 	if code_up {
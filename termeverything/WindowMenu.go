@@ -0,0 +1,150 @@
+package termeverything
+
+import (
+	"strings"
+
+	"github.com/mmulet/term.everything/escapecodes"
+	"github.com/mmulet/term.everything/framebuffertoansi"
+	"github.com/mmulet/term.everything/wayland"
+)
+
+/**
+ * Where the window menu is on the terminal. Cells are
+ * mapped to the desktop the same way pointer events are,
+ * so a click lands on the entry drawn under it.
+ */
+type WindowMenuLayout struct {
+	Row     int
+	Col     int
+	Width   int
+	Entries []wayland.WindowMenuEntry
+	/**
+	 * Index of the highlighted entry
+	 */
+	Selected int
+}
+
+/**
+ * ok is false if the menu isn't open.
+ * The clients must be locked.
+ */
+func LayoutWindowMenu(cols, rows int, desktop wayland.Size) (layout WindowMenuLayout, ok bool) {
+	x, y, entries, selected, ok := wayland.WindowMenu.Entries()
+	if !ok || cols <= 0 || rows <= 0 || desktop.Width == 0 || desktop.Height == 0 {
+		return layout, false
+	}
+	width := 0
+	for _, entry := range entries {
+		width = max(width, len(entry.Label))
+	}
+	/**
+	 * A space on each side
+	 */
+	width += 2
+	col := int(x * float32(cols) / float32(desktop.Width))
+	row := int(y * float32(rows) / float32(desktop.Height))
+	return WindowMenuLayout{
+		Row:      max(min(row, rows-len(entries)), 0),
+		Col:      max(min(col, cols-width), 0),
+		Width:    width,
+		Entries:  entries,
+		Selected: selected,
+	}, true
+}
+
+/**
+ * Index of the entry at col, row, or -1
+ */
+func (l WindowMenuLayout) EntryAt(col, row int) int {
+	if col < l.Col || col >= l.Col+l.Width || row < l.Row || row >= l.Row+len(l.Entries) {
+		return -1
+	}
+	return row - l.Row
+}
+
+func (l WindowMenuLayout) Draw() string {
+	var sb strings.Builder
+	for i, entry := range l.Entries {
+		sb.WriteString(framebuffertoansi.MoveCursorTo(l.Row+i, l.Col))
+		if i == l.Selected {
+			sb.WriteString(escapecodes.BgBlack + escapecodes.FgWhite)
+		} else {
+			sb.WriteString(escapecodes.BgWhite + escapecodes.FgBlack)
+		}
+		sb.WriteString(" " + entry.Label + strings.Repeat(" ", l.Width-len(entry.Label)-1))
+		sb.WriteString(escapecodes.Reset)
+	}
+	return sb.String()
+}
+
+/**
+ * The menu, to draw over the desktop, or "" if it isn't
+ * open. The clients must be locked.
+ */
+func WindowMenuOverlay(size *RenderedScreenSize, desktop wayland.Size) string {
+	if size.WidthCells == nil || size.HeightCells == nil {
+		return ""
+	}
+	layout, ok := LayoutWindowMenu(*size.WidthCells, *size.HeightCells, desktop)
+	if !ok {
+		return ""
+	}
+	return layout.Draw()
+}
+
+/**
+ * While the window menu is open it gets the keys and
+ * the mouse. Returns true if it used code.
+ * The clients must be locked.
+ */
+func (tw *TerminalWindow) WindowMenuInput(code XkbdCode) bool {
+	if _, ok := code.(*PointerButtonRelease); ok && tw.SwallowButtonRelease {
+		/**
+		 * The release of the click that closed the menu
+		 */
+		tw.SwallowButtonRelease = false
+		tw.PressedMouseButton = nil
+		return true
+	}
+	cols, rows := tw.CurrentTerminalSize()
+	layout, ok := LayoutWindowMenu(cols, rows, tw.VirtualMonitorSize)
+	if !ok {
+		return false
+	}
+	count := len(layout.Entries)
+	switch c := code.(type) {
+	case *KeyCode:
+		switch c.KeyCode {
+		case KEY_UP:
+			wayland.WindowMenu.Select((layout.Selected + count - 1) % count)
+		case KEY_DOWN, KEY_TAB:
+			wayland.WindowMenu.Select((layout.Selected + 1) % count)
+		case KEY_ENTER, KEY_SPACE:
+			wayland.WindowMenu.Activate(layout.Selected)
+		case KEY_ESC:
+			wayland.WindowMenu.Close()
+		}
+		return true
+	case *PointerMove:
+		tw.MouseCol, tw.MouseRow = c.Col, c.Row
+		if i := layout.EntryAt(c.Col, c.Row); i >= 0 {
+			wayland.WindowMenu.Select(i)
+		}
+		return true
+	case *PointerButtonPress:
+		tw.SwallowButtonRelease = true
+		if i := layout.EntryAt(tw.MouseCol, tw.MouseRow); i >= 0 {
+			wayland.WindowMenu.Activate(i)
+		} else {
+			wayland.WindowMenu.Close()
+		}
+		return true
+	case *PointerWheel:
+		return true
+	}
+	/**
+	 * The release of the click that opened the
+	 * menu still goes to the window.
+	 */
+	return false
+}
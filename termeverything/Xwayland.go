@@ -0,0 +1,379 @@
+package termeverything
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mmulet/term.everything/xwm"
+)
+
+/**
+ * What --support-old-apps turns on
+ */
+const SupportOldAppsXwaylandArgs = ":5 -rootless"
+
+/**
+ * How long to wait for Xwayland to open its display
+ */
+const XwaylandReadyTimeout = 10 * time.Second
+
+/**
+ * If Xwayland keeps crashing, give up after this many restarts
+ */
+const MaxXwaylandRestarts = 3
+
+/**
+ * How long processes get to exit after SIGTERM before SIGKILL
+ */
+const XwaylandStopTimeout = 2 * time.Second
+
+type XwaylandOptions struct {
+	/**
+	 * Arguments to Xwayland, like ":5 -rootless"
+	 */
+	Args []string
+	/**
+	 * Shell command to start the window manager,
+	 * empty means use the built in one.
+	 */
+	WMCommand string
+}
+
+/**
+ * Xwayland options from the command line,
+ * nil if Xwayland should not be started.
+ */
+func GetXwaylandOptions(args *CommandLineArgs) *XwaylandOptions {
+	xwaylandArgs := args.Xwayland
+	if xwaylandArgs == "" && args.SupportOldApps {
+		xwaylandArgs = SupportOldAppsXwaylandArgs
+	}
+	if strings.TrimSpace(xwaylandArgs) == "" {
+		return nil
+	}
+	return &XwaylandOptions{
+		Args:      strings.Fields(xwaylandArgs),
+		WMCommand: args.XwaylandWM,
+	}
+}
+
+/**
+ * Runs Xwayland and its window manager, restarts
+ * them if they crash, and stops them on exit.
+ */
+type XwaylandSupervisor struct {
+	Options XwaylandOptions
+	Shell   string
+	/**
+	 * Our end of Xwayland's wayland connection
+	 * is handed to the compositor through this.
+	 */
+	Connections chan<- *net.UnixConn
+
+	/**
+	 * The X display, like ":5", once Xwayland is ready.
+	 * Restarts keep it, so clients started with it can
+	 * reconnect. See GetDisplay.
+	 */
+	Display string
+
+	access   sync.Mutex
+	stopping bool
+	xwayland *Process
+	wm       *Process
+	builtin  *xwm.WindowManager
+	restarts int
+}
+
+/**
+ * A started command, Exited is closed once it has been waited on
+ */
+type Process struct {
+	Cmd    *exec.Cmd
+	Exited chan struct{}
+}
+
+func StartProcess(cmd *exec.Cmd) (*Process, error) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		/**
+		 * Its own process group, so stopping it also
+		 * stops anything it started.
+		 */
+		Setpgid:   true,
+		Pdeathsig: syscall.SIGTERM,
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	p := &Process{Cmd: cmd, Exited: make(chan struct{})}
+	go func() {
+		_ = cmd.Wait()
+		close(p.Exited)
+	}()
+	return p, nil
+}
+
+/**
+ * SIGTERM the process group, and SIGKILL
+ * it if it is still around after a while.
+ */
+func (p *Process) Stop() {
+	pid := p.Cmd.Process.Pid
+	_ = syscall.Kill(-pid, syscall.SIGTERM)
+	select {
+	case <-p.Exited:
+	case <-time.After(XwaylandStopTimeout):
+		_ = syscall.Kill(-pid, syscall.SIGKILL)
+		<-p.Exited
+	}
+}
+
+func MakeXwaylandSupervisor(options XwaylandOptions, shell string, connections chan<- *net.UnixConn) *XwaylandSupervisor {
+	return &XwaylandSupervisor{
+		Options:     options,
+		Shell:       shell,
+		Connections: connections,
+	}
+}
+
+/**
+ * Start Xwayland and the window manager, and wait for
+ * the display to be ready. After this Display is set.
+ */
+func (x *XwaylandSupervisor) Start() error {
+	return x.start(x.GetDisplay())
+}
+
+/**
+ * The X display, or "" if Xwayland never got ready
+ */
+func (x *XwaylandSupervisor) GetDisplay() string {
+	x.access.Lock()
+	defer x.access.Unlock()
+	return x.Display
+}
+
+/**
+ * Start them on display ("" to let Xwayland pick). This
+ * can take XwaylandReadyTimeout, so it runs without access
+ * held, and Stop doesn't have to wait for it.
+ */
+func (x *XwaylandSupervisor) start(display string) error {
+	xwayland, display, err := x.startXwayland(display)
+	if err != nil {
+		return err
+	}
+	wm, builtin := x.startWM(display)
+
+	x.access.Lock()
+	if x.stopping {
+		x.access.Unlock()
+		StopXwayland(builtin, wm, xwayland)
+		return nil
+	}
+	x.xwayland = xwayland
+	x.wm = wm
+	x.builtin = builtin
+	x.Display = display
+	x.access.Unlock()
+
+	go x.supervise(xwayland)
+	return nil
+}
+
+func (x *XwaylandSupervisor) startXwayland(display string) (*Process, string, error) {
+	/**
+	 * Xwayland connects to us over a socket pair instead of
+	 * the public socket, so we know which client it is.
+	 */
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create Xwayland socket pair: %w", err)
+	}
+	ourEnd := os.NewFile(uintptr(fds[0]), "xwayland-wayland-socket")
+	theirEnd := os.NewFile(uintptr(fds[1]), "xwayland-wayland-socket")
+	defer theirEnd.Close()
+
+	/**
+	 * Xwayland writes the display number
+	 * here once it is ready for clients.
+	 */
+	displayReader, displayWriter, err := os.Pipe()
+	if err != nil {
+		ourEnd.Close()
+		return nil, "", fmt.Errorf("failed to create Xwayland display pipe: %w", err)
+	}
+	defer displayReader.Close()
+
+	args := append([]string{}, x.Options.Args...)
+	if display != "" {
+		args = WithXDisplay(args, display)
+	}
+	/**
+	 * ExtraFiles start at fd 3
+	 */
+	args = append(args, "-displayfd", "3")
+	cmd := exec.Command("Xwayland", args...)
+	cmd.ExtraFiles = []*os.File{displayWriter, theirEnd}
+	cmd.Env = append(FilterEnv(os.Environ(), "WAYLAND_DISPLAY=", "WAYLAND_SOCKET=", "DISPLAY="),
+		"WAYLAND_SOCKET=4",
+	)
+	xwayland, err := StartProcess(cmd)
+	displayWriter.Close()
+	if err != nil {
+		ourEnd.Close()
+		return nil, "", fmt.Errorf("failed to start Xwayland: %w", err)
+	}
+
+	conn, err := net.FileConn(ourEnd)
+	ourEnd.Close()
+	if err != nil {
+		xwayland.Stop()
+		return nil, "", fmt.Errorf("failed to use Xwayland socket: %w", err)
+	}
+	x.Connections <- conn.(*net.UnixConn)
+
+	displayCh := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(displayReader).ReadString('\n')
+		displayCh <- strings.TrimSpace(line)
+	}()
+	select {
+	case display := <-displayCh:
+		if display == "" {
+			xwayland.Stop()
+			return nil, "", fmt.Errorf("Xwayland exited before opening a display")
+		}
+		return xwayland, ":" + display, nil
+	case <-time.After(XwaylandReadyTimeout):
+		xwayland.Stop()
+		return nil, "", fmt.Errorf("timed out waiting for Xwayland to open a display")
+	}
+}
+
+func (x *XwaylandSupervisor) startWM(display string) (*Process, *xwm.WindowManager) {
+	command := x.Options.WMCommand
+	if command == "" {
+		builtin, err := xwm.Start(display)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to start the Xwayland window manager: %v\n", err)
+			return nil, nil
+		}
+		return nil, builtin
+	}
+	cmd := exec.Command(x.Shell, "-c", command)
+	cmd.Env = append(FilterEnv(os.Environ(), "DISPLAY="), "DISPLAY="+display)
+	wm, err := StartProcess(cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start the Xwayland window manager: %v\n", err)
+		return nil, nil
+	}
+	return wm, nil
+}
+
+/**
+ * Restart Xwayland (and the window manager with it)
+ * if it exits before we ask it to.
+ */
+func (x *XwaylandSupervisor) supervise(xwayland *Process) {
+	<-xwayland.Exited
+
+	x.access.Lock()
+	if x.stopping || x.xwayland != xwayland {
+		x.access.Unlock()
+		return
+	}
+	wm := x.wm
+	builtin := x.builtin
+	x.wm = nil
+	x.builtin = nil
+	x.xwayland = nil
+	restarts := x.restarts
+	x.restarts++
+	display := x.Display
+	x.access.Unlock()
+
+	StopXwayland(builtin, wm, nil)
+	if restarts >= MaxXwaylandRestarts {
+		fmt.Fprintf(os.Stderr, "Xwayland exited %d times, not restarting it\n", restarts+1)
+		return
+	}
+	if err := x.start(display); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to restart Xwayland: %v\n", err)
+	}
+}
+
+/**
+ * Stop the window manager and Xwayland, waits
+ * for them to exit (or kills them).
+ */
+func (x *XwaylandSupervisor) Stop() {
+	x.access.Lock()
+	x.stopping = true
+	wm := x.wm
+	builtin := x.builtin
+	xwayland := x.xwayland
+	x.wm = nil
+	x.builtin = nil
+	x.xwayland = nil
+	x.access.Unlock()
+
+	StopXwayland(builtin, wm, xwayland)
+}
+
+/**
+ * Stop whichever of them isn't nil, the
+ * window manager first, then Xwayland.
+ */
+func StopXwayland(builtin *xwm.WindowManager, wm *Process, xwayland *Process) {
+	if builtin != nil {
+		builtin.Close()
+	}
+	if wm != nil {
+		wm.Stop()
+	}
+	if xwayland != nil {
+		xwayland.Stop()
+	}
+}
+
+/**
+ * Xwayland args with display in place of the one
+ * they had, or in front if they had none.
+ */
+func WithXDisplay(args []string, display string) []string {
+	for i, arg := range args {
+		if strings.HasPrefix(arg, ":") {
+			args[i] = display
+			return args
+		}
+	}
+	return append([]string{display}, args...)
+}
+
+/**
+ * env without the variables starting with any of prefixes
+ */
+func FilterEnv(env []string, prefixes ...string) []string {
+	filtered := make([]string, 0, len(env))
+	for _, e := range env {
+		keep := true
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(e, prefix) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
@@ -43,10 +43,21 @@ func ApplyWlSurfaceDoubleBufferedState(
 		surface.BufferTransform = *update.BufferTransform
 	}
 
+	surface.BufferDamage = nil
 	if update.Damage != nil || update.DamageBuffer != nil {
-		surface.Damaged = true
-	} else {
-		surface.Damaged = false
+		surface.BufferDamage = &Region{}
+		/**
+		 * wl_surface.damage is in surface coordinates,
+		 * the buffer is scale times bigger.
+		 * @TODO buffer transforms
+		 */
+		scale := max(surface.BufferScale, 1)
+		for _, r := range update.Damage {
+			surface.BufferDamage.Add(ScaleRect(ClampRect(r), scale))
+		}
+		for _, r := range update.DamageBuffer {
+			surface.BufferDamage.Add(ClampRect(r))
+		}
 	}
 
 	// offset: add to current offset (doc semantics)
@@ -78,17 +89,11 @@ func ApplyWlSurfaceDoubleBufferedState(
 	}
 
 	if update.InputRegion != nil {
-		if surface.InputRegion != nil && !AreSame(surface.InputRegion, update.InputRegion) {
-			RemoveObject(s, *surface.InputRegion)
-		}
-		surface.InputRegion = update.InputRegion
+		surface.InputRegion = update.InputRegion.Region
 	}
 
 	if update.OpaqueRegion != nil {
-		if surface.OpaqueRegion != nil && !AreSame(surface.OpaqueRegion, update.OpaqueRegion) {
-			RemoveObject(s, *surface.OpaqueRegion)
-		}
-		surface.OpaqueRegion = update.OpaqueRegion
+		surface.OpaqueRegion = update.OpaqueRegion.Region
 	}
 
 	if update.AddSubSurface != nil {
@@ -192,11 +197,8 @@ func ApplyWlSurfaceDoubleBufferedState(
 	// }
 
 	if update.XwaylandSurfarfaceV1Serial != nil {
-		if role, ok := surface.Role.(*SurfaceRoleXWaylandSurface); ok {
-			if role.Data == nil {
-				role.Data = &SurfaceRoleWaylandSurfaceData{}
-			}
-			(*role.Data).Serial = update.XwaylandSurfarfaceV1Serial
+		if role, ok := surface.Role.(*SurfaceRoleXWaylandSurface); ok && role.Data != nil {
+			role.Data.Serial = update.XwaylandSurfarfaceV1Serial
 		}
 	}
 
@@ -2,11 +2,14 @@ package wayland
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"slices"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/mmulet/term.everything/wayland/protocols"
@@ -19,8 +22,28 @@ const (
 	ClientStatus_Disconnected ClientStatus = 2
 )
 
+/**
+ * Object ids at or above this are allocated by the server,
+ * as described in the wayland wire format.
+ */
+const ServerObjectIDStart protocols.AnyObjectID = 0xff000000
+
+/**
+ * wl_display.error is the first event of wl_display
+ */
+const WlDisplayErrorOpcode = 0
+
 type Client struct {
-	Status ClientStatus
+	/**
+	 * A ClientStatus, read from other clients' goroutines
+	 * and the loops without the client locked. See GetStatus.
+	 */
+	status atomic.Int32
+	/**
+	 * Held while checking status and queueing an event,
+	 * so nothing is queued after Destroy drains the queue.
+	 */
+	outgoingAccess sync.Mutex
 
 	drawableSurfaces map[protocols.ObjectID[protocols.WlSurface]]bool
 	topLevelSurfaces map[protocols.ObjectID[protocols.XdgToplevel]]bool
@@ -34,12 +57,22 @@ type Client struct {
 	messageBuffer []byte
 
 	OutgoingChannel chan protocols.OutgoingEvent
+	/**
+	 * Closed when the client is destroyed, stops the writer
+	 */
+	closed chan struct{}
 
 	Decoder *MessageDecoder
 
 	UnclaimedFDs []protocols.FileDescriptor
 
 	Objects map[protocols.AnyObjectID]any
+	/**
+	 * See ObjectVersion. Events to this client are sent from
+	 * other clients' goroutines too, so it has its own lock.
+	 */
+	objectVersions       map[protocols.AnyObjectID]uint32
+	objectVersionsAccess sync.Mutex
 
 	RolesToSurfaces map[protocols.AnyObjectID]protocols.ObjectID[protocols.WlSurface]
 
@@ -49,6 +82,31 @@ type Client struct {
 
 	LastGetMessageTime time.Time
 
+	/**
+	 * Work other goroutines queued to run with this
+	 * client locked, see QueueTask. It grows as needed,
+	 * a lost focus change or offer would never be resent.
+	 */
+	tasks       []func()
+	tasksAccess sync.Mutex
+	/**
+	 * Has a value when tasks isn't empty
+	 */
+	tasksReady chan struct{}
+
+	/**
+	 * Connected over the socket pair we made
+	 * when starting Xwayland, not the public socket.
+	 */
+	Xwayland bool
+
+	/**
+	 * Set for clients of a sandbox, see wp_security_context_v1
+	 */
+	SecurityContext *protocols.SecurityContext
+
+	nextServerObjectID protocols.AnyObjectID
+
 	Access sync.Mutex
 }
 
@@ -56,6 +114,40 @@ func (c *Client) AddFrameDrawRequest(cb protocols.ObjectID[protocols.WlCallback]
 	c.FrameDrawRequests <- cb
 }
 
+/**
+ * Run task on the client's goroutine with it locked.
+ * Never blocks, callers often hold other locks.
+ */
+func (c *Client) QueueTask(task func()) {
+	if c.GetStatus() == ClientStatus_Disconnected {
+		return
+	}
+	c.tasksAccess.Lock()
+	c.tasks = append(c.tasks, task)
+	c.tasksAccess.Unlock()
+	select {
+	case c.tasksReady <- struct{}{}:
+	default:
+	}
+}
+
+/**
+ * Take every queued task
+ */
+func (c *Client) takeTasks() []func() {
+	c.tasksAccess.Lock()
+	defer c.tasksAccess.Unlock()
+	tasks := c.tasks
+	c.tasks = nil
+	return tasks
+}
+
+func (c *Client) NewServerObjectID() protocols.AnyObjectID {
+	id := c.nextServerObjectID
+	c.nextServerObjectID++
+	return id
+}
+
 func (c *Client) GetSurfaceIDFromRole(roleObjectID protocols.AnyObjectID) *protocols.ObjectID[protocols.WlSurface] {
 	if sid, ok := c.RolesToSurfaces[roleObjectID]; ok {
 		return &sid
@@ -156,17 +248,83 @@ func (c *Client) AddObject(id protocols.AnyObjectID, v any) {
 
 func (c *Client) RemoveObject(id protocols.AnyObjectID) {
 	delete(c.Objects, id)
+	c.forgetObjectVersion(id)
 }
 
+/**
+ * Objects that were never given a version, like
+ * wl_display, are version 1.
+ */
+func (c *Client) ObjectVersion(id protocols.AnyObjectID) uint32 {
+	c.objectVersionsAccess.Lock()
+	defer c.objectVersionsAccess.Unlock()
+	if version, ok := c.objectVersions[id]; ok {
+		return version
+	}
+	return 1
+}
+
+func (c *Client) SetObjectVersion(id protocols.AnyObjectID, version uint32) {
+	c.objectVersionsAccess.Lock()
+	defer c.objectVersionsAccess.Unlock()
+	c.objectVersions[id] = version
+}
+
+func (c *Client) forgetObjectVersion(id protocols.AnyObjectID) {
+	c.objectVersionsAccess.Lock()
+	defer c.objectVersionsAccess.Unlock()
+	delete(c.objectVersions, id)
+}
+
+/**
+ * The object is gone for good, forget it and the surface
+ * it was the role of. If the client picked the id, tell it
+ * the id can be used again.
+ */
+func (c *Client) DestroyObject(id protocols.AnyObjectID) {
+	delete(c.Objects, id)
+	c.forgetObjectVersion(id)
+	c.UnregisterRoleToSurface(id)
+	if id < ServerObjectIDStart {
+		protocols.WlDisplay_delete_id(c, c.DisplayID, uint32(id))
+	}
+}
+
+/**
+ * New ids from the client have to be in the client's
+ * range, and not belong to an object that is still alive.
+ */
+func (c *Client) ValidNewID(id protocols.AnyObjectID) bool {
+	_, live := c.Objects[id]
+	if id != 0 && id < ServerObjectIDStart && !live {
+		return true
+	}
+	c.SendError(
+		protocols.AnyObjectID(c.DisplayID),
+		uint32(protocols.WlDisplayError_enum_invalid_object),
+		fmt.Sprintf("invalid new id %d", uint32(id)),
+	)
+	return false
+}
+
+/**
+ * The client's object with id, or nil. Globals are only
+ * the client's once bound (except wl_display, which every
+ * client starts with), so a sandboxed client can't reach
+ * one by sending requests to its global id.
+ */
 func (c *Client) GetObject(id protocols.AnyObjectID) any {
 	object, ok := c.Objects[id]
 	if !ok {
-		return c.GetGlobalObjectByID(uint32(id))
+		if id == protocols.AnyObjectID(c.DisplayID) {
+			return Global_WlDisplay
+		}
+		return nil
 	}
 	return object
 }
 
-func (c *Client) GetGlobalObjectByID(globalID uint32) any {
+func GetGlobalObjectByID(globalID uint32) any {
 	switch globalID {
 	case uint32(protocols.GlobalID_WlDisplay):
 		return Global_WlDisplay
@@ -198,13 +356,16 @@ func (c *Client) GetGlobalObjectByID(globalID uint32) any {
 		return Global_WlTouch
 	case uint32(protocols.GlobalID_ZxdgDecorationManagerV1):
 		return Global_ZxdgDecorationManagerV1
+	case uint32(protocols.GlobalID_ZwpPrimarySelectionDeviceManagerV1):
+		return Global_ZwpPrimarySelectionDeviceManagerV1
+	case uint32(protocols.GlobalID_WpSecurityContextManagerV1):
+		return Global_WpSecurityContextManagerV1
 	}
 	return nil
 }
 
 func MakeClient(conn *net.UnixConn) *Client {
 	return &Client{
-		Status:            ClientStatus_Connected,
 		UnixConnection:    conn,
 		CompositorVersion: 1,
 		Decoder:           MakeMessageDecoder(),
@@ -213,9 +374,11 @@ func MakeClient(conn *net.UnixConn) *Client {
 		messageBuffer: make([]byte, 64*1024),
 
 		OutgoingChannel: make(chan protocols.OutgoingEvent, 8192),
+		closed:          make(chan struct{}),
 
 		UnclaimedFDs:    make([]protocols.FileDescriptor, 0, 8),
 		Objects:         make(map[protocols.AnyObjectID]any),
+		objectVersions:  make(map[protocols.AnyObjectID]uint32),
 		RolesToSurfaces: make(map[protocols.AnyObjectID]protocols.ObjectID[protocols.WlSurface]),
 
 		drawableSurfaces: make(map[protocols.ObjectID[protocols.WlSurface]]bool),
@@ -223,67 +386,146 @@ func MakeClient(conn *net.UnixConn) *Client {
 
 		GlobalBinds:       make(map[protocols.GlobalID]any),
 		FrameDrawRequests: make(chan protocols.ObjectID[protocols.WlCallback], 1024),
+		tasksReady:        make(chan struct{}, 1),
+
+		nextServerObjectID: ServerObjectIDStart,
 	}
 }
 
+/**
+ * Requests are read and dispatched on one goroutine, events
+ * are written on another, and tasks run on this one. Each
+ * waits on its own channel or socket, so an idle client
+ * doesn't use any CPU.
+ */
 func (c *Client) MainLoop() error {
-	defer func() {
-		c.Status = ClientStatus_Disconnected
-		if c.UnixConnection != nil {
-			if err := c.UnixConnection.Close(); err != nil {
+	defer c.Destroy()
+	errs := make(chan error, 2)
+	go c.readLoop(errs)
+	go c.writeLoop(errs)
+	for {
+		select {
+		case <-c.tasksReady:
+			for _, task := range c.takeTasks() {
+				c.runTask(task)
 			}
+		case err := <-errs:
+			return err
 		}
-	}()
+	}
+}
+
+func (c *Client) runTask(task func()) {
+	c.Access.Lock()
+	defer c.Access.Unlock()
+	task()
+}
+
+func (c *Client) readLoop(errs chan<- error) {
+	oob := MakeGetMessageOOB()
 	for {
+		n, fds, err := GetMessageAndFileDescriptors(c.UnixConnection, c.messageBuffer, oob)
+		if err != nil {
+			errs <- err
+			return
+		}
+		if err := c.ParseMessages(n, fds); err != nil {
+			var malformed *MalformedMessageError
+			if errors.As(err, &malformed) {
+				/**
+				 * Nothing after it can be read, writeLoop
+				 * disconnects the client once it has sent
+				 * the client the error.
+				 */
+				return
+			}
+			errs <- err
+			return
+		}
+	}
+}
 
-		for {
+/**
+ * Most bytes and fds to send in one sendmsg. libwayland
+ * clients take at most 28 fds at a time.
+ */
+const (
+	MaxSendBatchBytes = 16 * 1024
+	MaxSendBatchFDs   = 28
+)
+
+/**
+ * Send every event queued so far in one sendmsg. If the
+ * client's socket buffer is full the write waits for it to
+ * read, and Send starts failing once the queue fills up.
+ */
+func (c *Client) writeLoop(errs chan<- error) {
+	buf := make([]byte, 0, MaxSendBatchBytes)
+	fds := make([]int, 0, MaxSendBatchFDs)
+	var next *protocols.OutgoingEvent
+	for {
+		if next == nil {
 			select {
 			case ev := <-c.OutgoingChannel:
-
-				if err := c.SendPendingMessage(ev); err != nil {
-					return err
+				next = &ev
+			case <-c.closed:
+				return
+			}
+		}
+		buf, fds = buf[:0], fds[:0]
+		to_close := make([]int, 0)
+		sent_error := false
+		for next != nil {
+			ev := *next
+			next = nil
+			buf = appendEvent(buf, ev)
+			if ev.ObjectID == protocols.AnyObjectID(c.DisplayID) && ev.Opcode == WlDisplayErrorOpcode {
+				sent_error = true
+			}
+			if ev.FileDescriptor != nil {
+				fds = append(fds, int(*ev.FileDescriptor))
+				if ev.CloseFileDescriptorAfterSend {
+					to_close = append(to_close, int(*ev.FileDescriptor))
 				}
+			}
+			select {
+			case ev := <-c.OutgoingChannel:
+				next = &ev
 			default:
-				goto drained
+			}
+			if next != nil &&
+				(len(buf)+8+len(next.Data) > MaxSendBatchBytes ||
+					(next.FileDescriptor != nil && len(fds) >= MaxSendBatchFDs)) {
+				break
 			}
 		}
-	drained:
-
-		// Receive once with short deadline; parse and dispatch.
-		n, fds, err := GetMessageAndFileDescriptors(c.UnixConnection, c.messageBuffer)
+		err := SendMessageAndFileDescriptors(c.UnixConnection, buf, fds)
+		for _, fd := range to_close {
+			syscall.Close(fd)
+		}
 		if err != nil {
-			// treat unexpected read errors as fatal
-			return err
+			errs <- err
+			return
 		}
-		if err := c.ParseMessages(n, fds); err != nil {
-			return err
+		if sent_error {
+			/**
+			 * A protocol error is fatal,
+			 * the client can't go on after it.
+			 */
+			errs <- fmt.Errorf("client was sent a protocol error")
+			return
 		}
 	}
 }
 
-func (c *Client) Send(ev protocols.OutgoingEvent) {
-	// Allow backpressure to naturally block the sender goroutine.
-	c.OutgoingChannel <- ev
-}
-
 /**
- *
- * @param message
- * @returns Returns if we should continue listening or sending on this socket any more
- * returns falsy mostly if the client has disconnected
+ * Append the wire format of ev to buf
  */
-func (c *Client) SendPendingMessage(ev protocols.OutgoingEvent) error {
-	c.Access.Lock()
-	defer c.Access.Unlock()
+func appendEvent(buf []byte, ev protocols.OutgoingEvent) []byte {
 	if protocols.DebugRequests {
 		log.Printf("client -> eid=%d opcode=%d len=%d fd=%v",
 			uint32(ev.ObjectID), ev.Opcode, len(ev.Data), ev.FileDescriptor)
 	}
-	// if WaylandDebugTimeOnly() {
-
-	// 	log.Printf("client -> eid=%d opcode=%d len=%d fd=%v",
-	// 		uint32(ev.ObjectID), ev.Opcode, len(ev.Data), ev.FileDescriptor)
-	// }
 	/**
 	 * 8 bytes is the header length + the length of the message
 	 * #### Header is
@@ -292,35 +534,137 @@ func (c *Client) SendPendingMessage(ev protocols.OutgoingEvent) error {
 	 * - 2 bytes for size
 	 */
 	size := 8 + len(ev.Data)
-	buf := make([]byte, size)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(ev.ObjectID))
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(ev.Opcode))
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(size))
+	return append(buf, ev.Data...)
+}
+
+/**
+ * The client disconnected, free everything it had: its
+ * place in the global state, the memory of its shm pools,
+ * file descriptors nothing claimed, and its objects.
+ */
+func (c *Client) Destroy() {
+	c.Access.Lock()
+	defer c.Access.Unlock()
+	c.outgoingAccess.Lock()
+	c.status.Store(int32(ClientStatus_Disconnected))
+	c.outgoingAccess.Unlock()
+	close(c.closed)
+	c.takeTasks()
+	Clipboard.RemoveClient(c)
+	PrimarySelection.RemoveClient(c)
+	DragAndDrop.RemoveClient(c)
+	KeyboardFocus.RemoveClient(c)
+	Pointer.RemoveClient(c)
+	XWindows.RemoveClient(c)
+	MoveResize.RemoveClient(c)
+	PopupGrab.RemoveClient(c)
+	WindowMenu.RemoveClient(c)
+
+	/**
+	 * A destroyed pool's buffers still have its
+	 * delegate, so look for pools through both.
+	 */
+	for _, object := range c.Objects {
+		var pool *WlShmPool
+		switch o := object.(type) {
+		case *protocols.WlShmPool:
+			pool, _ = o.Delegate.(*WlShmPool)
+		case *protocols.WlBuffer:
+			pool, _ = o.Delegate.(*WlShmPool)
+		}
+		if pool != nil && pool.MapState != MapStateDestroyed {
+			pool.OnDestroyShmPool(c, pool.WlShmPoolObjectID)
+		}
+	}
 
-	// Wayland header: object_id (u32), size (u16), opcode (u16)
-	binary.LittleEndian.PutUint32(buf[0:4], uint32(ev.ObjectID))
-	binary.LittleEndian.PutUint16(buf[4:6], uint16(ev.Opcode))
-	binary.LittleEndian.PutUint16(buf[6:8], uint16(size))
-	copy(buf[8:], ev.Data)
+	for _, fd := range c.UnclaimedFDs {
+		syscall.Close(int(fd))
+	}
+	c.UnclaimedFDs = nil
+	for {
+		select {
+		case ev := <-c.OutgoingChannel:
+			if ev.FileDescriptor != nil && ev.CloseFileDescriptorAfterSend {
+				syscall.Close(int(*ev.FileDescriptor))
+			}
+		default:
+			goto drained
+		}
+	}
+drained:
+
+	clear(c.Objects)
+	c.objectVersionsAccess.Lock()
+	clear(c.objectVersions)
+	c.objectVersionsAccess.Unlock()
+	clear(c.RolesToSurfaces)
+	clear(c.drawableSurfaces)
+	clear(c.topLevelSurfaces)
+	clear(c.GlobalBinds)
+	if c.UnixConnection != nil {
+		c.UnixConnection.Close()
+	}
+}
 
-	var fds []int
-	if ev.FileDescriptor != nil {
-		fds = []int{int(*ev.FileDescriptor)}
+func (c *Client) GetStatus() ClientStatus {
+	return ClientStatus(c.status.Load())
+}
+
+func (c *Client) Send(ev protocols.OutgoingEvent) {
+	c.outgoingAccess.Lock()
+	defer c.outgoingAccess.Unlock()
+	if c.GetStatus() == ClientStatus_Disconnected {
+		/**
+		 * Nothing reads the channel anymore
+		 */
+		if ev.FileDescriptor != nil && ev.CloseFileDescriptorAfterSend {
+			syscall.Close(int(*ev.FileDescriptor))
+		}
+		return
+	}
+	select {
+	case c.OutgoingChannel <- ev:
+	default:
+		/**
+		 * The client stopped reading. Like libwayland, give
+		 * up on it instead of blocking whoever is sending
+		 * (maybe the draw loop, with every client locked).
+		 */
+		log.Printf("client: outgoing queue is full, disconnecting")
+		if ev.FileDescriptor != nil && ev.CloseFileDescriptorAfterSend {
+			syscall.Close(int(*ev.FileDescriptor))
+		}
+		/**
+		 * The loops drop it now, MainLoop
+		 * destroys it once the read fails.
+		 */
+		c.status.Store(int32(ClientStatus_Disconnected))
+		c.UnixConnection.Close()
 	}
-	return SendMessageAndFileDescriptors(c.UnixConnection, buf, fds)
-	// re
-
-	// if err != nil {
-	// 	// if EPIPE or similar => disconnect
-	// 	if errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) {
-	// 		return false
-	// 	}
-	// 	log.Printf("Send error: %v (n=%d ok=%v)", err, n, ok)
-	// 	return false
-	// }
-	// return true
 }
 
+/**
+ *
+ * @param message
+ * @returns Returns if we should continue listening or sending on this socket any more
+ * returns falsy mostly if the client has disconnected
+ */
 func (c *Client) ParseMessages(n int, fds []int) error {
 	c.Access.Lock()
 	defer c.Access.Unlock()
+	if c.GetStatus() == ClientStatus_Disconnected {
+		/**
+		 * Destroy already ran (or is about to), requests now would
+		 * put the client back in the global state.
+		 */
+		for _, fd := range fds {
+			syscall.Close(fd)
+		}
+		return net.ErrClosed
+	}
 	// if len(fds) > 0 && WaylandDebugTimeOnly() {
 	// 	log.Printf("client: received %d file descriptors", len(fds))
 	// }
@@ -332,14 +676,7 @@ func (c *Client) ParseMessages(n int, fds []int) error {
 		return fmt.Errorf("negative byte count received: %d", n)
 	}
 
-	if n == 0 {
-		/**
-		* Time out
-		 */
-		return nil
-	}
-
-	msgs := c.Decoder.Consume(c.messageBuffer[:n])
+	msgs, decode_err := c.Decoder.Consume(c.messageBuffer[:n])
 	for i := range msgs {
 		m := msgs[i]
 		obj := c.GetObject(m.ObjectID)
@@ -357,7 +694,15 @@ func (c *Client) ParseMessages(n int, fds []int) error {
 		}
 		theType.OnRequest(c, m)
 	}
-	return nil
+	var malformed *MalformedMessageError
+	if errors.As(decode_err, &malformed) {
+		c.SendError(
+			malformed.ObjectID,
+			uint32(protocols.WlDisplayError_enum_invalid_method),
+			decode_err.Error(),
+		)
+	}
+	return decode_err
 }
 
 func (c *Client) ClaimFileDescriptor() *protocols.FileDescriptor {
@@ -369,6 +714,10 @@ func (c *Client) ClaimFileDescriptor() *protocols.FileDescriptor {
 	return &fd
 }
 
+func (c *Client) IsXwayland() bool { return c.Xwayland }
+func (c *Client) GetSecurityContext() *protocols.SecurityContext {
+	return c.SecurityContext
+}
 func (c *Client) SetCompositorVersion(v uint32) { c.CompositorVersion = v }
 func (c *Client) GetCompositorVersion() uint32  { return c.CompositorVersion }
 
@@ -65,6 +65,15 @@ func (c *Client) AddGlobalWlDataDeviceBind(objectID protocols.ObjectID[protocols
 	binds.(map[protocols.ObjectID[protocols.WlDataDevice]]protocols.Version)[objectID] = version
 }
 
+func (c *Client) AddGlobalWlDataDeviceManagerBind(objectID protocols.ObjectID[protocols.WlDataDeviceManager], version protocols.Version) {
+	binds, ok := c.GlobalBinds[protocols.GlobalID_WlDataDeviceManager]
+	if !ok {
+		binds = make(map[protocols.ObjectID[protocols.WlDataDeviceManager]]protocols.Version)
+		c.GlobalBinds[protocols.GlobalID_WlDataDeviceManager] = binds
+	}
+	binds.(map[protocols.ObjectID[protocols.WlDataDeviceManager]]protocols.Version)[objectID] = version
+}
+
 func (c *Client) AddGlobalZwpXwaylandKeyboardGrabManagerV1Bind(objectID protocols.ObjectID[protocols.ZwpXwaylandKeyboardGrabManagerV1], version protocols.Version) {
 	binds, ok := c.GlobalBinds[protocols.GlobalID_ZwpXwaylandKeyboardGrabManagerV1]
 	if !ok {
@@ -129,6 +138,14 @@ func (c *Client) RemoveGlobalWlDataDeviceBind(objectID protocols.ObjectID[protoc
 	delete(binds.(map[protocols.ObjectID[protocols.WlDataDevice]]protocols.Version), objectID)
 }
 
+func (c *Client) RemoveGlobalWlDataDeviceManagerBind(objectID protocols.ObjectID[protocols.WlDataDeviceManager]) {
+	binds, ok := c.GlobalBinds[protocols.GlobalID_WlDataDeviceManager]
+	if !ok {
+		return
+	}
+	delete(binds.(map[protocols.ObjectID[protocols.WlDataDeviceManager]]protocols.Version), objectID)
+}
+
 func (c *Client) RemoveGlobalZwpXwaylandKeyboardGrabManagerV1Bind(objectID protocols.ObjectID[protocols.ZwpXwaylandKeyboardGrabManagerV1]) {
 	binds, ok := c.GlobalBinds[protocols.GlobalID_ZwpXwaylandKeyboardGrabManagerV1]
 	if !ok {
@@ -6,6 +6,11 @@ import (
 	"github.com/mmulet/term.everything/wayland/protocols"
 )
 
+/**
+ * We only advertise argb8888 and xrgb8888
+ */
+const BytesPerPixel = 4
+
 func CopyBufferToWlSurfaceTexture(
 	s protocols.ClientState,
 	surfaceID protocols.ObjectID[protocols.WlSurface],
@@ -49,6 +54,9 @@ func CopyBufferToWlSurfaceTexture(
 
 	x := surface.Offset.X
 	y := surface.Offset.Y
+	drawable := true
+	var toplevel *protocols.ObjectID[protocols.XdgToplevel]
+	var popup *protocols.ObjectID[protocols.XdgPopup]
 
 	if surface.Role == nil {
 		return
@@ -56,7 +64,14 @@ func CopyBufferToWlSurfaceTexture(
 
 	switch role := surface.Role.(type) {
 	case *SurfaceRoleXdgPopup:
-		return
+		if !role.HasData() {
+			return
+		}
+		/**
+		 * Placed next to its parent, once the
+		 * texture has the size of this buffer.
+		 */
+		popup = role.Data
 	case *SurfaceRoleSubSurface:
 		if role.Data != nil {
 			sub_surface := GetWlSubsurfaceObject(s, *role.Data)
@@ -70,24 +85,23 @@ func CopyBufferToWlSurfaceTexture(
 		}
 	case *SurfaceRoleXWaylandSurface:
 		/**
-		 * @TODO
+		 * X windows are drawn where the X window manager
+		 * put them, once we know which window it is. Without
+		 * the built in one they are drawn at their offset.
 		 */
-		fmt.Println("ON commit xwayland_surface_v1")
-	case *SurfaceRoleXdgToplevel:
-		if surface.XdgSurfaceState != nil {
-			xdg_surface_state := GetXdgSurfaceObject(s, *surface.XdgSurfaceState)
-			if xdg_surface_state != nil {
-				// x = surface.xdg_surface_state.window_geometry.x;
-				// y = surface.xdg_surface_state.window_geometry.y;
-				// console.log(
-				//   "reposition xdg_toplevel, ",
-				//   x,
-				//   y,
-				//   "for surface",
-				//   surface_id
-				// );
-			}
+		if !XWindows.HasManager() {
+			break
 		}
+		drawable = false
+		if role.Data != nil && role.Data.Serial != nil {
+			x, y, drawable = XWindows.SurfaceCommitted(s, surfaceID, role.Data.Serial.Value())
+		}
+	case *SurfaceRoleXdgToplevel:
+		/**
+		 * Floating windows are placed once the
+		 * texture has the size of this buffer.
+		 */
+		toplevel = role.Data
 	case *SurfaceRoleCursor:
 		/**
 		 * @TODO is this right?
@@ -104,7 +118,12 @@ func CopyBufferToWlSurfaceTexture(
 		}
 		x += int32(Pointer.WindowX) + role.Data.Hotspot.X
 		y += int32(Pointer.WindowY) + role.Data.Hotspot.Y
-
+	case *SurfaceRoleDragIcon:
+		/**
+		 * Position is relative to the pointer,
+		 * Desktop.DrawClients draws it there while
+		 * the drag is active.
+		 */
 	}
 	surface.Position.X = x
 	surface.Position.Y = y
@@ -118,6 +137,12 @@ func CopyBufferToWlSurfaceTexture(
 		}
 	}
 
+	/**
+	 * A malformed buffer whose rows don't fit in its stride
+	 * can't be copied by rows, copy all of it instead.
+	 */
+	fullCopy := surface.Texture == nil ||
+		int(bufferInfo.Width)*BytesPerPixel > int(bufferInfo.Stride)
 	if surface.Texture == nil {
 		size := int(bufferInfo.Stride) * int(bufferInfo.Height)
 		if size < 0 {
@@ -153,7 +178,46 @@ func CopyBufferToWlSurfaceTexture(
 
 	}
 
-	copy(surface.Texture.Data, src[offset:offset+total])
+	bounds := Rect{Width: bufferInfo.Width, Height: bufferInfo.Height}
+	if fullCopy {
+		copy(surface.Texture.Data, src[offset:offset+total])
+		surface.AddDamage(bounds)
+	} else if surface.BufferDamage != nil {
+		/**
+		 * The texture already has the last buffer's
+		 * content, only copy the rows of each damaged
+		 * rect that changed.
+		 */
+		stride := int(bufferInfo.Stride)
+		for _, r := range surface.BufferDamage.Rects {
+			r = r.Intersect(bounds)
+			if r.IsEmpty() {
+				continue
+			}
+			start := int(r.X) * BytesPerPixel
+			end := int(r.X+r.Width) * BytesPerPixel
+			for row := int(r.Y); row < int(r.Y+r.Height); row++ {
+				copy(
+					surface.Texture.Data[row*stride+start:row*stride+end],
+					src[offset+row*stride+start:offset+row*stride+end],
+				)
+			}
+			surface.AddDamage(r)
+		}
+	}
+	surface.BufferDamage = nil
+
+	if toplevel != nil {
+		PlaceToplevel(s, *toplevel)
+	}
+	if popup != nil {
+		PlacePopup(s, *popup)
+		PopupGrab.Committed(s, *popup)
+	}
 
+	if !drawable {
+		delete(s.DrawableSurfaces(), surfaceID)
+		return
+	}
 	s.DrawableSurfaces()[surfaceID] = true
 }
@@ -0,0 +1,388 @@
+package wayland
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mmulet/term.everything/wayland/protocols"
+)
+
+/**
+ * The surface a drag is currently over, and the
+ * offers made to each of its client's data devices.
+ */
+type DragFocus struct {
+	Client    protocols.ClientState
+	SurfaceID protocols.ObjectID[protocols.WlSurface]
+	Offers    map[protocols.ObjectID[protocols.WlDataDevice]]protocols.ObjectID[protocols.WlDataOffer]
+}
+
+/**
+ * Something that can be dragged, a client's
+ * wl_data_source or text pasted into the host terminal.
+ */
+type DragSource interface {
+	SelectionSource
+	GetActions() protocols.WlDataDeviceManagerDndAction_enum
+	IsDestroyed() bool
+	Target(mime_type *string)
+	Action(action protocols.WlDataDeviceManagerDndAction_enum)
+	DropPerformed()
+	Finished()
+}
+
+/**
+ * How long a client gets to accept a paste
+ * dropped on it before the offer is withdrawn.
+ */
+const HostDropTimeout = time.Second
+
+type DragState struct {
+	Access sync.Mutex
+
+	Active bool
+	/**
+	 * The drag is a paste from the host terminal, it is
+	 * dropped as soon as the client accepts it, and does
+	 * not follow the pointer.
+	 */
+	FromHost bool
+
+	OriginClient protocols.ClientState
+	Origin       protocols.ObjectID[protocols.WlSurface]
+	/**
+	 * nil when the drag is within the origin client,
+	 * in that case only its own surfaces get events.
+	 */
+	Source DragSource
+	/**
+	 * Belongs to OriginClient
+	 */
+	Icon *protocols.ObjectID[protocols.WlSurface]
+
+	Focus *DragFocus
+
+	Devices map[protocols.ClientState]map[protocols.ObjectID[protocols.WlDataDevice]]*wl_data_device
+}
+
+var DragAndDrop = DragState{
+	Devices: make(map[protocols.ClientState]map[protocols.ObjectID[protocols.WlDataDevice]]*wl_data_device),
+}
+
+func (d *DragState) AddDevice(s protocols.ClientState, id protocols.ObjectID[protocols.WlDataDevice], device *wl_data_device) {
+	d.Access.Lock()
+	defer d.Access.Unlock()
+	devices, ok := d.Devices[s]
+	if !ok {
+		devices = make(map[protocols.ObjectID[protocols.WlDataDevice]]*wl_data_device)
+		d.Devices[s] = devices
+	}
+	devices[id] = device
+}
+
+func (d *DragState) RemoveDevice(s protocols.ClientState, id protocols.ObjectID[protocols.WlDataDevice]) {
+	d.Access.Lock()
+	defer d.Access.Unlock()
+	if devices, ok := d.Devices[s]; ok {
+		delete(devices, id)
+		if len(devices) == 0 {
+			delete(d.Devices, s)
+		}
+	}
+	if d.Focus != nil && d.Focus.Client == s {
+		delete(d.Focus.Offers, id)
+	}
+}
+
+func (d *DragState) RemoveClient(s protocols.ClientState) {
+	d.Access.Lock()
+	defer d.Access.Unlock()
+	delete(d.Devices, s)
+	if d.Focus != nil && d.Focus.Client == s {
+		d.Focus = nil
+	}
+	if d.Active && d.OriginClient == s {
+		d.cancel()
+	}
+}
+
+/**
+ * Is the pointer dragging something
+ */
+func (d *DragState) IsActive() bool {
+	d.Access.Lock()
+	defer d.Access.Unlock()
+	return d.Active && !d.FromHost
+}
+
+/**
+ * Called from start_drag, the drag follows the
+ * terminal's pointer from the next motion on.
+ */
+func (d *DragState) Start(
+	s protocols.ClientState,
+	source DragSource,
+	origin protocols.ObjectID[protocols.WlSurface],
+	icon *protocols.ObjectID[protocols.WlSurface],
+) {
+	d.Access.Lock()
+	defer d.Access.Unlock()
+	if d.Active {
+		if source != nil {
+			source.Cancel()
+		}
+		return
+	}
+	d.Active = true
+	d.FromHost = false
+	d.OriginClient = s
+	d.Origin = origin
+	d.Source = source
+	d.Icon = icon
+	d.Focus = nil
+	/**
+	 * Only the data device gets events during the
+	 * drag, the pointer enters again once it ends
+	 * (see WlPointer.Refresh).
+	 */
+	Pointer.Leave(s)
+}
+
+/**
+ * The surface to draw at the pointer, if there
+ * is a drag with an icon. OriginClient must be locked.
+ */
+func (d *DragState) IconSurface() *WlSurface {
+	d.Access.Lock()
+	defer d.Access.Unlock()
+	if !d.Active || d.Icon == nil {
+		return nil
+	}
+	return GetWlSurfaceObject(d.OriginClient, *d.Icon)
+}
+
+/**
+ * Pointer moved to x, y (in desktop coordinates).
+ * All clients must be locked.
+ */
+func (d *DragState) Motion(clients []*Client, x, y float32, time uint32) {
+	d.Access.Lock()
+	defer d.Access.Unlock()
+	if !d.Active || d.FromHost {
+		return
+	}
+	hit := FindSurfaceAt(clients, x, y)
+	if hit != nil && d.Source == nil && protocols.ClientState(hit.Client) != d.OriginClient {
+		hit = nil
+	}
+
+	if d.Focus != nil && hit != nil &&
+		d.Focus.Client == protocols.ClientState(hit.Client) &&
+		d.Focus.SurfaceID == hit.SurfaceID {
+		for deviceID := range d.Devices[d.Focus.Client] {
+			protocols.WlDataDevice_motion(d.Focus.Client, deviceID, time, hit.X, hit.Y)
+		}
+		return
+	}
+
+	d.leave()
+	if hit != nil {
+		d.enter(hit)
+	}
+}
+
+func (d *DragState) enter(hit *SurfaceHit) {
+	serial := NextSerial()
+
+	focus := &DragFocus{
+		Client:    hit.Client,
+		SurfaceID: hit.SurfaceID,
+		Offers:    make(map[protocols.ObjectID[protocols.WlDataDevice]]protocols.ObjectID[protocols.WlDataOffer]),
+	}
+	for deviceID := range d.Devices[focus.Client] {
+		if d.Source == nil {
+			protocols.WlDataDevice_enter(hit.Client, deviceID, serial, hit.SurfaceID, hit.X, hit.Y, nil)
+			continue
+		}
+		offerID := protocols.ObjectID[protocols.WlDataOffer](hit.Client.NewServerObjectID())
+		offer := MakeWlDataOffer(d.Source)
+		dragOffer := offer.Delegate.(*WlDataOffer)
+		dragOffer.Client = hit.Client
+		dragOffer.ID = offerID
+		dragOffer.DragAndDrop = true
+		hit.Client.AddObject(protocols.AnyObjectID(offerID), offer)
+
+		protocols.WlDataDevice_data_offer(hit.Client, deviceID, offerID)
+		for _, mimeType := range d.Source.GetMimeTypes() {
+			protocols.WlDataOffer_offer(hit.Client, offerID, mimeType)
+		}
+		protocols.WlDataOffer_source_actions(hit.Client, offerID, d.Source.GetActions())
+		protocols.WlDataDevice_enter(hit.Client, deviceID, serial, hit.SurfaceID, hit.X, hit.Y, &offerID)
+		focus.Offers[deviceID] = offerID
+	}
+	d.Focus = focus
+}
+
+func (d *DragState) leave() {
+	if d.Focus == nil {
+		return
+	}
+	for deviceID := range d.Devices[d.Focus.Client] {
+		protocols.WlDataDevice_leave(d.Focus.Client, deviceID)
+	}
+	if d.Source != nil {
+		d.Source.Target(nil)
+	}
+	d.Focus = nil
+}
+
+/**
+ * The pointer button was released.
+ * All clients must be locked.
+ */
+func (d *DragState) Drop() {
+	d.Access.Lock()
+	defer d.Access.Unlock()
+	if !d.Active || d.FromHost {
+		return
+	}
+	if d.Focus == nil {
+		d.cancel()
+		return
+	}
+	if d.Source == nil {
+		for deviceID := range d.Devices[d.Focus.Client] {
+			protocols.WlDataDevice_drop(d.Focus.Client, deviceID)
+		}
+		d.end()
+		return
+	}
+
+	accepted := false
+	for _, offerID := range d.Focus.Offers {
+		offer := GetWlDataOfferObject(d.Focus.Client, offerID)
+		if offer != nil && offer.WillAcceptDrop() {
+			accepted = true
+		}
+	}
+	if !accepted || d.Source.IsDestroyed() {
+		d.cancel()
+		return
+	}
+	for deviceID := range d.Focus.Offers {
+		protocols.WlDataDevice_drop(d.Focus.Client, deviceID)
+	}
+	d.Source.DropPerformed()
+	d.end()
+}
+
+/**
+ * Offer a paste to the keyboard focused client as if it had
+ * been dragged to where the pointer is. It is dropped once the
+ * client accepts it. Returns false if it can't be offered (the
+ * pointer isn't over the client, or it has no data device),
+ * refused is called if it isn't accepted within HostDropTimeout.
+ * All clients must be locked.
+ */
+func (d *DragState) DropFromHost(source DragSource, refused func()) bool {
+	d.Access.Lock()
+	defer d.Access.Unlock()
+	if d.Active {
+		return false
+	}
+	client, ok := KeyboardFocus.FocusedClient().(*Client)
+	if !ok {
+		return false
+	}
+	/**
+	 * A drop lands on whatever is at its position,
+	 * without one it would miss the text field.
+	 */
+	surface_id, x, y, ok := Pointer.Over(client)
+	if !ok {
+		return false
+	}
+	surface := GetWlSurfaceObject(client, surface_id)
+	if surface == nil {
+		return false
+	}
+	hit := &SurfaceHit{
+		Client:    client,
+		SurfaceID: surface_id,
+		Surface:   surface,
+		X:         x,
+		Y:         y,
+	}
+	d.Active = true
+	d.FromHost = true
+	d.Source = source
+	d.enter(hit)
+	if len(d.Focus.Offers) == 0 {
+		d.end()
+		return false
+	}
+	go func() {
+		time.Sleep(HostDropTimeout)
+		hit.Client.QueueTask(func() {
+			d.Access.Lock()
+			defer d.Access.Unlock()
+			if d.Active && d.FromHost && d.Source == source {
+				d.cancel()
+				go refused()
+			}
+		})
+	}()
+	return true
+}
+
+/**
+ * The client accepted a mime type or set its actions
+ * on a drag offer, drop a paste as soon as it can.
+ */
+func (d *DragState) OfferChanged(offer *WlDataOffer) {
+	d.Access.Lock()
+	defer d.Access.Unlock()
+	if !d.Active || !d.FromHost || d.Focus == nil || d.Focus.Client != offer.Client {
+		return
+	}
+	if !offer.WillAcceptDrop() {
+		return
+	}
+	for deviceID, offerID := range d.Focus.Offers {
+		if offerID != offer.ID {
+			continue
+		}
+		protocols.WlDataDevice_drop(offer.Client, deviceID)
+		d.Source.DropPerformed()
+		d.end()
+		return
+	}
+}
+
+/**
+ * The source was destroyed in the middle of a drag
+ */
+func (d *DragState) SourceDestroyed(source DragSource) {
+	d.Access.Lock()
+	defer d.Access.Unlock()
+	if d.Active && d.Source == source {
+		d.cancel()
+	}
+}
+
+func (d *DragState) cancel() {
+	d.leave()
+	if d.Source != nil {
+		d.Source.Cancel()
+	}
+	d.end()
+}
+
+func (d *DragState) end() {
+	d.Active = false
+	d.FromHost = false
+	d.OriginClient = nil
+	d.Source = nil
+	d.Icon = nil
+	d.Focus = nil
+}
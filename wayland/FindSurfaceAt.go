@@ -0,0 +1,145 @@
+package wayland
+
+import (
+	"sort"
+
+	"github.com/mmulet/term.everything/wayland/protocols"
+)
+
+type SurfaceHit struct {
+	Client    *Client
+	SurfaceID protocols.ObjectID[protocols.WlSurface]
+	Surface   *WlSurface
+	/**
+	 * Surface local coordinates
+	 */
+	X float32
+	Y float32
+}
+
+type surfaceHitCandidate struct {
+	client    *Client
+	surfaceID protocols.ObjectID[protocols.WlSurface]
+	surface   *WlSurface
+	x, y      int32
+	stacking  WindowStacking
+	minimized bool
+}
+
+/**
+ * Find the top most surface under x, y (in desktop
+ * coordinates). Surfaces are ordered and positioned
+ * the same way Desktop.DrawClients draws them.
+ * The clients must be locked.
+ */
+func FindSurfaceAt(clients []*Client, x, y float32) *SurfaceHit {
+	candidates := make([]surfaceHitCandidate, 0, 64)
+	type parentLocation struct {
+		parentID protocols.ObjectID[protocols.WlSurface]
+		x, y     int32
+	}
+
+	for _, c := range clients {
+		if c == nil || c.GetStatus() != ClientStatus_Connected {
+			continue
+		}
+		childToParent := make(map[protocols.ObjectID[protocols.WlSurface]]parentLocation)
+		for surfaceID := range c.DrawableSurfaces() {
+			surface := GetWlSurfaceObject(c, surfaceID)
+			if surface == nil || surface.Texture == nil {
+				continue
+			}
+			for _, child := range surface.ChildrenInDrawOrder {
+				if child == nil {
+					continue
+				}
+				childToParent[*child] = parentLocation{
+					parentID: surfaceID,
+					x:        surface.Position.X,
+					y:        surface.Position.Y,
+				}
+			}
+			switch surface.Role.(type) {
+			case *SurfaceRoleCursor, *SurfaceRoleDragIcon:
+				continue
+			}
+			candidates = append(candidates, surfaceHitCandidate{
+				client:    c,
+				surfaceID: surfaceID,
+				surface:   surface,
+			})
+		}
+		for i := range candidates {
+			if candidates[i].client != c {
+				continue
+			}
+			cx := candidates[i].surface.Position.X
+			cy := candidates[i].surface.Position.Y
+			root := candidates[i].surfaceID
+			parent, ok := childToParent[root]
+			for ok {
+				cx += parent.x
+				cy += parent.y
+				root = parent.parentID
+				parent, ok = childToParent[root]
+			}
+			candidates[i].stacking, candidates[i].minimized = SurfaceStacking(c, root)
+			candidates[i].x = cx
+			candidates[i].y = cy
+		}
+	}
+
+	/**
+	 * Top most first, the reverse of the draw order
+	 */
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].stacking != candidates[j].stacking {
+			return candidates[j].stacking.Below(candidates[i].stacking)
+		}
+		zi := candidates[i].surface.Position.Z
+		zj := candidates[j].surface.Position.Z
+		if zi == zj {
+			return candidates[i].surfaceID > candidates[j].surfaceID
+		}
+		return zi > zj
+	})
+
+	for _, it := range candidates {
+		if it.minimized {
+			continue
+		}
+		localX := x - float32(it.x)
+		localY := y - float32(it.y)
+		if !it.surface.AcceptsInput(localX, localY) {
+			continue
+		}
+		return &SurfaceHit{
+			Client:    it.client,
+			SurfaceID: it.surfaceID,
+			Surface:   it.surface,
+			X:         localX,
+			Y:         localY,
+		}
+	}
+	return nil
+}
+
+/**
+ * Is x, y (relative to the surface's top left, as drawn)
+ * part of the surface for pointer input. The surface
+ * must have a texture.
+ */
+func (w *WlSurface) AcceptsInput(x, y float32) bool {
+	/**
+	 * The texture is drawn a pixel per buffer pixel, the
+	 * surface size and input region are scale times smaller.
+	 */
+	scale := float32(max(w.BufferScale, 1))
+	x, y = x/scale, y/scale
+	if x < 0 || y < 0 ||
+		x >= float32(w.Texture.Width)/scale ||
+		y >= float32(w.Texture.Height)/scale {
+		return false
+	}
+	return w.InputRegion == nil || w.InputRegion.Contains(x, y)
+}
@@ -1,46 +1,37 @@
 package wayland
 
 import (
-	"errors"
 	"io"
 	"net"
 	"syscall"
-	"time"
 )
 
 const (
-	GetMessage_timeout      = 1 * time.Millisecond
 	GetMessage_maxFDsInCmsg = 10  // matches C++: CMSG_SPACE(sizeof(int) * 10)
 	GetMessage_hardFDLimit  = 255 // matches C++ guard in the copy loop
 	GetMessage_intSizeBytes = 4   // sizeof(int) on Linux
 )
 
-var oob = make([]byte, syscall.CmsgSpace(GetMessage_intSizeBytes*GetMessage_maxFDsInCmsg))
-
-func GetMessageAndFileDescriptors(conn *net.UnixConn, buf []byte) (n int, fds []int, err error) {
-
-	// 10ms "select"-style timeout
-	if err := conn.SetReadDeadline(time.Now().Add(GetMessage_timeout)); err != nil {
-		return 0, nil, err
-	}
-	defer conn.SetReadDeadline(time.Time{})
+/**
+ * Room for the fds of one read, each reader needs its own
+ */
+func MakeGetMessageOOB() []byte {
+	return make([]byte, syscall.CmsgSpace(GetMessage_intSizeBytes*GetMessage_maxFDsInCmsg))
+}
 
+/**
+ * Block until the client sends something (the runtime's
+ * poller waits, it doesn't spin). Returns io.EOF
+ * once the client hung up.
+ */
+func GetMessageAndFileDescriptors(conn *net.UnixConn, buf []byte, oob []byte) (n int, fds []int, err error) {
 	n, oobn, _, _, rerr := conn.ReadMsgUnix(buf, oob)
-
-	// Timeout -> continue with no data/fds.
-	if ne, ok := rerr.(net.Error); ok && ne.Timeout() {
-		return 0, nil, nil
-	}
 	if rerr != nil {
-		if errors.Is(rerr, io.EOF) {
-			return n, nil, nil
-		}
-		// Treat as terminal like the C++ (returns false).
 		return n, nil, rerr
 	}
 	if n == 0 {
 		// EOF on stream
-		return 0, nil, nil
+		return 0, nil, io.EOF
 	}
 
 	// Parse as many rights as fit; ignore truncation like the C++.
@@ -21,3 +21,7 @@ var Global_WlDataDevice = Global_WlSeat
 var Global_WlTouch = MakeWlTouch()
 
 var Global_ZxdgDecorationManagerV1 = MakeZxdgDecorationManagerV1()
+
+var Global_ZwpPrimarySelectionDeviceManagerV1 = MakeZwpPrimarySelectionDeviceManagerV1()
+
+var Global_WpSecurityContextManagerV1 = MakeWpSecurityContextManagerV1()
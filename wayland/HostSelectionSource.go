@@ -0,0 +1,127 @@
+package wayland
+
+import (
+	"bytes"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mmulet/term.everything/wayland/protocols"
+)
+
+const URIListMimeType = "text/uri-list"
+
+/**
+ * A selection that came from the host terminal, either
+ * through bracketed paste or an OSC 52 reply.
+ */
+type HostSelectionSource struct {
+	MimeTypes []string
+	Data      []byte
+	/**
+	 * nil unless every line of Data is a path that exists
+	 */
+	URIList []byte
+}
+
+func MakeHostSelectionSource(data []byte) *HostSelectionSource {
+	h := &HostSelectionSource{
+		MimeTypes: TextMimeTypes,
+		Data:      data,
+		URIList:   PathsToURIList(data),
+	}
+	if h.URIList != nil {
+		h.MimeTypes = append([]string{URIListMimeType}, TextMimeTypes...)
+	}
+	return h
+}
+
+/**
+ * Terminals paste (and drop) files as their absolute paths,
+ * one per line, sometimes as file:// uris. If every line
+ * resolves to a file that exists, return them as a text/uri-list.
+ * Relative paths are left alone, otherwise any pasted word that
+ * happens to name a file in our working directory would turn
+ * into a file.
+ */
+func PathsToURIList(data []byte) []byte {
+	lines := strings.Split(strings.TrimRight(string(data), "\r\n"), "\n")
+	out := bytes.Buffer{}
+	for _, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			return nil
+		}
+		path := line
+		if strings.HasPrefix(line, "file://") {
+			u, err := url.Parse(line)
+			if err != nil {
+				return nil
+			}
+			path = u.Path
+		}
+		if strings.HasPrefix(path, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil
+			}
+			path = filepath.Join(home, path[2:])
+		}
+		if !filepath.IsAbs(path) {
+			return nil
+		}
+		if _, err := os.Stat(path); err != nil {
+			return nil
+		}
+		u := url.URL{Scheme: "file", Path: path}
+		out.WriteString(u.String())
+		out.WriteString("\r\n")
+	}
+	return out.Bytes()
+}
+
+func (h *HostSelectionSource) GetMimeTypes() []string {
+	return h.MimeTypes
+}
+
+func (h *HostSelectionSource) SendTo(mime_type string, fd protocols.FileDescriptor) {
+	data := h.Data
+	if mime_type == URIListMimeType && h.URIList != nil {
+		data = h.URIList
+	}
+	go func() {
+		writer := os.NewFile(uintptr(fd), "selection")
+		defer writer.Close()
+		if _, err := writer.Write(data); err != nil {
+			log.Printf("HostSelectionSource: failed to write selection: %v", err)
+		}
+	}()
+}
+
+func (h *HostSelectionSource) Cancel() {}
+
+func (h *HostSelectionSource) Owner() protocols.ClientState {
+	return nil
+}
+
+/**
+ * DragSource methods, for when a paste is dropped on a surface
+ */
+
+func (h *HostSelectionSource) GetActions() protocols.WlDataDeviceManagerDndAction_enum {
+	return protocols.WlDataDeviceManagerDndAction_enum_copy
+}
+
+func (h *HostSelectionSource) IsDestroyed() bool {
+	return false
+}
+
+func (h *HostSelectionSource) Target(_ *string) {}
+
+func (h *HostSelectionSource) Action(_ protocols.WlDataDeviceManagerDndAction_enum) {}
+
+func (h *HostSelectionSource) DropPerformed() {}
+
+func (h *HostSelectionSource) Finished() {}
@@ -0,0 +1,322 @@
+package wayland
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/mmulet/term.everything/wayland/protocols"
+)
+
+type FocusedSurface struct {
+	Client    protocols.ClientState
+	SurfaceID protocols.ObjectID[protocols.WlSurface]
+}
+
+/**
+ * Keyboard focus, there is only one focused surface at a time,
+ * and only its client gets key events.
+ */
+type FocusState struct {
+	Access sync.Mutex
+
+	/**
+	 * nil when nothing has focus
+	 */
+	Focused *FocusedSurface
+
+	/**
+	 * Surfaces that had focus, most recent last.
+	 * When the focused surface goes away the
+	 * one before it gets focus back.
+	 */
+	History []FocusedSurface
+
+	/**
+	 * The surface each client was last sent an enter for,
+	 * and not a leave. Enter and leave for a client other
+	 * than the one whose request we are handling are sent
+	 * from its own task queue, so this can lag behind Focused.
+	 */
+	Entered map[protocols.ClientState]protocols.ObjectID[protocols.WlSurface]
+
+	/**
+	 * Set by zwp_xwayland_keyboard_grab_v1, while it is
+	 * set focus stays on it and it gets every key, even
+	 * the ones the status line would have used.
+	 */
+	Grab *FocusedSurface
+}
+
+var KeyboardFocus = FocusState{
+	Entered: make(map[protocols.ClientState]protocols.ObjectID[protocols.WlSurface]),
+}
+
+/**
+ * The client that should get key events, or nil
+ */
+func (f *FocusState) FocusedClient() protocols.ClientState {
+	f.Access.Lock()
+	defer f.Access.Unlock()
+	if f.Focused == nil {
+		return nil
+	}
+	return f.Focused.Client
+}
+
+/**
+ * Does surface_id have keyboard focus
+ */
+func (f *FocusState) IsFocused(s protocols.ClientState, surface_id protocols.ObjectID[protocols.WlSurface]) bool {
+	f.Access.Lock()
+	defer f.Access.Unlock()
+	return f.Focused != nil && f.Focused.Client == s && f.Focused.SurfaceID == surface_id
+}
+
+/**
+ * Give surface_id keyboard focus. s must be locked,
+ * the client losing focus is told from its task queue.
+ * Returns false if a keyboard grab kept focus where it was.
+ */
+func (f *FocusState) Focus(s protocols.ClientState, surface_id protocols.ObjectID[protocols.WlSurface]) bool {
+	f.Access.Lock()
+	defer f.Access.Unlock()
+	return f.focus(s, surface_id)
+}
+
+func (f *FocusState) focus(s protocols.ClientState, surface_id protocols.ObjectID[protocols.WlSurface]) bool {
+	if f.Grab != nil && (f.Grab.Client != s || f.Grab.SurfaceID != surface_id) {
+		return false
+	}
+	if f.Focused != nil && f.Focused.Client == s && f.Focused.SurfaceID == surface_id {
+		return true
+	}
+	old := f.Focused
+	f.Focused = &FocusedSurface{Client: s, SurfaceID: surface_id}
+	f.History = slices.DeleteFunc(f.History, func(h FocusedSurface) bool {
+		return h == *f.Focused
+	})
+	f.History = append(f.History, *f.Focused)
+
+	f.enter(s, surface_id)
+
+	if old != nil && old.Client != s {
+		f.queueLeave(old.Client)
+	}
+	return true
+}
+
+/**
+ * Route all keys to surface_id until EndGrab. s must be locked.
+ */
+func (f *FocusState) StartGrab(s protocols.ClientState, surface_id protocols.ObjectID[protocols.WlSurface]) {
+	f.Access.Lock()
+	defer f.Access.Unlock()
+	f.Grab = nil
+	f.focus(s, surface_id)
+	f.Grab = &FocusedSurface{Client: s, SurfaceID: surface_id}
+}
+
+/**
+ * End the grab on surface_id, if it is the one grabbing
+ */
+func (f *FocusState) EndGrab(s protocols.ClientState, surface_id protocols.ObjectID[protocols.WlSurface]) {
+	f.Access.Lock()
+	defer f.Access.Unlock()
+	if f.Grab != nil && f.Grab.Client == s && f.Grab.SurfaceID == surface_id {
+		f.Grab = nil
+	}
+}
+
+/**
+ * End any grab, for when the user asks
+ * to focus another window.
+ */
+func (f *FocusState) BreakGrab() {
+	f.Access.Lock()
+	defer f.Access.Unlock()
+	f.Grab = nil
+}
+
+/**
+ * Is a client grabbing the keyboard
+ */
+func (f *FocusState) IsGrabbed() bool {
+	f.Access.Lock()
+	defer f.Access.Unlock()
+	return f.Grab != nil
+}
+
+/**
+ * The surface lost its toplevel role, but still exists.
+ * s must be locked.
+ */
+func (f *FocusState) SurfaceUnmapped(s protocols.ClientState, surface_id protocols.ObjectID[protocols.WlSurface]) {
+	f.Access.Lock()
+	defer f.Access.Unlock()
+	if entered, ok := f.Entered[s]; ok && entered == surface_id {
+		f.leave(s, false)
+	}
+	f.forget(func(h FocusedSurface) bool {
+		return h.Client == s && h.SurfaceID == surface_id
+	})
+}
+
+/**
+ * The surface is gone, so it can't be in a leave event.
+ * s must be locked.
+ */
+func (f *FocusState) SurfaceDestroyed(s protocols.ClientState, surface_id protocols.ObjectID[protocols.WlSurface]) {
+	f.Access.Lock()
+	defer f.Access.Unlock()
+	if entered, ok := f.Entered[s]; ok && entered == surface_id {
+		delete(f.Entered, s)
+	}
+	f.forget(func(h FocusedSurface) bool {
+		return h.Client == s && h.SurfaceID == surface_id
+	})
+}
+
+/**
+ * Forget everything about a client that has disconnected
+ */
+func (f *FocusState) RemoveClient(s protocols.ClientState) {
+	f.Access.Lock()
+	defer f.Access.Unlock()
+	delete(f.Entered, s)
+	f.forget(func(h FocusedSurface) bool {
+		return h.Client == s
+	})
+}
+
+/**
+ * Send enter to a keyboard the client just created,
+ * if the client already has focus. s must be locked.
+ */
+func (f *FocusState) KeyboardCreated(s protocols.ClientState, keyboard_id protocols.ObjectID[protocols.WlKeyboard]) {
+	f.Access.Lock()
+	defer f.Access.Unlock()
+	surface_id, ok := f.Entered[s]
+	if !ok {
+		return
+	}
+	protocols.WlKeyboard_enter(s, keyboard_id, NextSerial(), surface_id, []byte{})
+	protocols.WlKeyboard_modifiers(s, keyboard_id, NextSerial(), 0, 0, 0, 0)
+}
+
+/**
+ * Remove the matching surfaces from the history, and if
+ * the focused surface was one of them, focus the most
+ * recent one left.
+ */
+func (f *FocusState) forget(matches func(h FocusedSurface) bool) {
+	if f.Grab != nil && matches(*f.Grab) {
+		f.Grab = nil
+	}
+	f.History = slices.DeleteFunc(f.History, matches)
+	if f.Focused == nil || !matches(*f.Focused) {
+		return
+	}
+	f.Focused = nil
+	if len(f.History) == 0 {
+		return
+	}
+	next := f.History[len(f.History)-1]
+	f.Focused = &next
+	next.Client.QueueTask(func() {
+		f.Access.Lock()
+		defer f.Access.Unlock()
+		if f.Focused == nil || *f.Focused != next {
+			return
+		}
+		f.enter(next.Client, next.SurfaceID)
+	})
+}
+
+func (f *FocusState) queueLeave(s protocols.ClientState) {
+	s.QueueTask(func() {
+		f.Access.Lock()
+		defer f.Access.Unlock()
+		if f.Focused != nil && f.Focused.Client == s {
+			/**
+			 * Focus came back to s before this ran,
+			 * the enter already took care of it.
+			 */
+			return
+		}
+		f.leave(s, true)
+	})
+}
+
+func (f *FocusState) enter(s protocols.ClientState, surface_id protocols.ObjectID[protocols.WlSurface]) {
+	if entered, ok := f.Entered[s]; ok {
+		if entered == surface_id {
+			return
+		}
+		f.leave(s, true)
+	}
+	f.Entered[s] = surface_id
+	toplevelActivated(s, surface_id, true)
+	Clipboard.FocusEntered(s)
+	PrimarySelection.FocusEntered(s)
+	for keyboard_id := range protocols.GetGlobalWlKeyboardBinds(s) {
+		protocols.WlKeyboard_enter(s, keyboard_id, NextSerial(), surface_id, []byte{})
+		/**
+		 * Nothing is held down when focus
+		 * changes, keys are released instantly.
+		 */
+		protocols.WlKeyboard_modifiers(s, keyboard_id, NextSerial(), 0, 0, 0, 0)
+	}
+}
+
+/**
+ * mapped is false when the surface is being unmapped,
+ * then it isn't configured again.
+ */
+func (f *FocusState) leave(s protocols.ClientState, mapped bool) {
+	surface_id, ok := f.Entered[s]
+	if !ok {
+		return
+	}
+	delete(f.Entered, s)
+	if mapped {
+		toplevelActivated(s, surface_id, false)
+	}
+	for keyboard_id := range protocols.GetGlobalWlKeyboardBinds(s) {
+		protocols.WlKeyboard_leave(s, keyboard_id, NextSerial(), surface_id)
+	}
+}
+
+/**
+ * The surface that takes keyboard focus when surface_id
+ * is clicked, its toplevel (or X window), following
+ * subsurfaces up to their parent. nil if it can't take focus.
+ */
+func FocusableSurface(s protocols.ClientState, surface_id protocols.ObjectID[protocols.WlSurface]) *protocols.ObjectID[protocols.WlSurface] {
+	for range 64 {
+		surface := GetWlSurfaceObject(s, surface_id)
+		if surface == nil {
+			return nil
+		}
+		switch role := surface.Role.(type) {
+		case *SurfaceRoleXdgToplevel:
+			if !role.HasData() {
+				return nil
+			}
+			return &surface_id
+		case *SurfaceRoleXWaylandSurface:
+			return &surface_id
+		case *SurfaceRoleSubSurface:
+			if role.Data == nil {
+				return nil
+			}
+			subsurface := GetWlSubsurfaceObject(s, *role.Data)
+			if subsurface == nil {
+				return nil
+			}
+			surface_id = subsurface.Parent
+		default:
+			return nil
+		}
+	}
+	return nil
+}
@@ -1,110 +1,128 @@
 package wayland
 
-import "github.com/mmulet/term.everything/wayland/protocols"
+import (
+	"encoding/binary"
+	"fmt"
 
-const (
-	stateObjectID protocols.DecodeStateType = iota
-	stateOpcode
-	stateSize
-	stateData
+	"github.com/mmulet/term.everything/wayland/protocols"
 )
 
-func initialDecodeState() protocols.DecodeState {
-	return protocols.DecodeState{
-		Phase:    stateObjectID,
-		I:        0,
-		ObjectID: 0,
-		Opcode:   0,
-		Size:     0,
-		Data:     nil,
-	}
+/**
+ * 4 bytes for the object id, 2 for the opcode and 2 for
+ * the size, which counts the header too.
+ */
+const MessageHeaderSize = 8
+
+/**
+ * The client sent a header that can't be right, after
+ * it there is no telling where the next message starts.
+ */
+type MalformedMessageError struct {
+	ObjectID protocols.AnyObjectID
+	Size     int
+}
+
+func (e *MalformedMessageError) Error() string {
+	return fmt.Sprintf("malformed message for object %d: size %d", uint32(e.ObjectID), e.Size)
 }
 
+/**
+ * Splits what is read from a client into messages. The
+ * messages' Data are slices of the buffer passed to Consume,
+ * so they are only valid until the next Consume.
+ */
 type MessageDecoder struct {
-	state protocols.DecodeState
+	/**
+	 * The start of a message that
+	 * didn't all come in the last read
+	 */
+	pending []byte
+
+	messages []protocols.Message
 }
 
 func MakeMessageDecoder() *MessageDecoder {
 	return &MessageDecoder{
-		state: initialDecodeState(),
+		messages: make([]protocols.Message, 0, 64),
 	}
 }
 
-func (d *MessageDecoder) reset() {
-	d.state = initialDecodeState()
-}
+/**
+ * The whole messages in pending and buf. A message cut off at
+ * the end of buf is kept and finished by the next Consume. On a
+ * malformed header the messages before it are still returned.
+ */
+func (d *MessageDecoder) Consume(buf []byte) ([]protocols.Message, error) {
+	d.messages = d.messages[:0]
 
-func (d *MessageDecoder) nextState() {
-	d.state.I = 0
-	switch d.state.Phase {
-	case stateObjectID:
-		d.state.Phase = stateOpcode
-		d.state.Opcode = 0
-	case stateOpcode:
-		d.state.Phase = stateSize
-		d.state.Size = 0
-	case stateSize:
-		d.state.Phase = stateData
-		d.state.Data = d.state.Data[:0]
-		// 8 is the header size; no payload means return to initial state
-		if d.state.Size == 8 {
-			d.reset()
+	if len(d.pending) > 0 {
+		if len(d.pending) < MessageHeaderSize {
+			take := min(MessageHeaderSize-len(d.pending), len(buf))
+			d.pending = append(d.pending, buf[:take]...)
+			buf = buf[take:]
+			if len(d.pending) < MessageHeaderSize {
+				return d.messages, nil
+			}
+		}
+		size, err := messageSize(d.pending)
+		if err != nil {
+			return d.messages, err
+		}
+		take := min(size-len(d.pending), len(buf))
+		d.pending = append(d.pending, buf[:take]...)
+		buf = buf[take:]
+		if len(d.pending) < size {
+			return d.messages, nil
 		}
-	case stateData:
-		d.reset()
+		/**
+		 * The message keeps pending's memory,
+		 * the next cut off message gets its own.
+		 */
+		d.messages = append(d.messages, decodeMessage(d.pending))
+		d.pending = nil
+	}
+
+	for len(buf) >= MessageHeaderSize {
+		size, err := messageSize(buf)
+		if err != nil {
+			return d.messages, err
+		}
+		if len(buf) < size {
+			break
+		}
+		d.messages = append(d.messages, decodeMessage(buf[:size]))
+		buf = buf[size:]
 	}
-}
 
-func (d *MessageDecoder) Consume(buf []byte) []protocols.Message {
+	if len(buf) > 0 {
+		d.pending = append([]byte(nil), buf...)
+	}
+	return d.messages, nil
+}
 
-	out := make([]protocols.Message, 0)
-	for _, b := range buf {
-		switch d.state.Phase {
-		case stateObjectID:
-			d.state.ObjectID |= protocols.AnyObjectID(b) << d.state.I
-			d.state.I += 8
-			if d.state.I == 32 {
-				d.nextState()
-			}
-		case stateOpcode:
-			d.state.Opcode |= uint16(b) << d.state.I
-			d.state.I += 8
-			if d.state.I == 16 {
-				d.nextState()
-			}
-		case stateSize:
-			d.state.Size |= uint16(b) << d.state.I
-			d.state.I += 8
-			if d.state.I == 16 {
-				if d.state.Size == 8 {
-					// zero-size payload message (header-only)
-					out = append(out, protocols.Message{
-						ObjectID: d.state.ObjectID,
-						Opcode:   d.state.Opcode,
-						Size:     d.state.Size,
-						Data:     []byte{},
-					})
-				}
-				d.nextState()
-			}
-		case stateData:
-			d.state.Data = append(d.state.Data, b)
-			// size includes 8-byte header, so payload length is size-8
-			want := int(d.state.Size) - 8
-			if len(d.state.Data) == want {
-				// copy data to detach from internal buffer
-				payload := make([]byte, want)
-				copy(payload, d.state.Data)
-				out = append(out, protocols.Message{
-					ObjectID: d.state.ObjectID,
-					Opcode:   d.state.Opcode,
-					Size:     d.state.Size,
-					Data:     payload,
-				})
-				d.nextState()
-			}
+/**
+ * The size from the header at the start of b, which
+ * is at least the header and a multiple of 4.
+ */
+func messageSize(b []byte) (int, error) {
+	size := int(binary.LittleEndian.Uint16(b[6:8]))
+	if size < MessageHeaderSize || size%4 != 0 {
+		return 0, &MalformedMessageError{
+			ObjectID: protocols.AnyObjectID(binary.LittleEndian.Uint32(b[0:4])),
+			Size:     size,
 		}
 	}
+	return size, nil
+}
 
-	return out
+/**
+ * b is exactly one message
+ */
+func decodeMessage(b []byte) protocols.Message {
+	return protocols.Message{
+		ObjectID: protocols.AnyObjectID(binary.LittleEndian.Uint32(b[0:4])),
+		Opcode:   binary.LittleEndian.Uint16(b[4:6]),
+		Size:     uint16(len(b)),
+		Data:     b[MessageHeaderSize:],
+	}
 }
@@ -0,0 +1,124 @@
+package wayland
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/mmulet/term.everything/wayland/protocols"
+)
+
+func appendTestMessage(buf []byte, object_id uint32, opcode uint16, size uint16, payload int) []byte {
+	buf = binary.LittleEndian.AppendUint32(buf, object_id)
+	buf = binary.LittleEndian.AppendUint16(buf, opcode)
+	buf = binary.LittleEndian.AppendUint16(buf, size)
+	for i := range payload {
+		buf = append(buf, byte(i))
+	}
+	return buf
+}
+
+/**
+ * Decode buf in chunks with the lengths from cuts (repeated),
+ * up to the first error. Data is copied, it is only valid
+ * until the next Consume.
+ */
+func decodeInChunks(buf []byte, cuts []byte) ([]protocols.Message, error) {
+	d := MakeMessageDecoder()
+	out := make([]protocols.Message, 0)
+	for i := 0; len(buf) > 0; i++ {
+		n := len(buf)
+		if len(cuts) > 0 {
+			n = min(n, 1+int(cuts[i%len(cuts)]))
+		}
+		msgs, err := d.Consume(buf[:n])
+		for _, m := range msgs {
+			m.Data = bytes.Clone(m.Data)
+			out = append(out, m)
+		}
+		if err != nil {
+			return out, err
+		}
+		buf = buf[n:]
+	}
+	return out, nil
+}
+
+func FuzzMessageDecoderConsume(f *testing.F) {
+	valid := appendTestMessage(nil, 1, 0, 12, 4)
+	valid = appendTestMessage(valid, 2, 1, 8, 0)
+	valid = appendTestMessage(valid, 3, 2, 20, 12)
+
+	f.Add(valid, []byte{})
+	// header split across reads
+	f.Add(valid, []byte{2, 5, 0})
+	// message split across reads
+	f.Add(valid, []byte{9, 15})
+	// one byte at a time
+	f.Add(valid, []byte{0})
+	// size below 8
+	f.Add(appendTestMessage(bytes.Clone(valid), 4, 0, 4, 0), []byte{3})
+	f.Add(appendTestMessage(nil, 4, 0, 0, 0), []byte{})
+	// size not divisible by 4
+	f.Add(appendTestMessage(bytes.Clone(valid), 4, 0, 13, 5), []byte{6, 1})
+	// cut off at the end
+	f.Add(valid[:len(valid)-3], []byte{4})
+
+	f.Fuzz(func(t *testing.T, buf []byte, cuts []byte) {
+		whole, whole_err := decodeInChunks(buf, nil)
+		chunked, chunked_err := decodeInChunks(buf, cuts)
+
+		if (whole_err == nil) != (chunked_err == nil) {
+			t.Fatalf("error in one call %v, in chunks %v", whole_err, chunked_err)
+		}
+		if whole_err != nil {
+			var malformed *MalformedMessageError
+			if !errors.As(whole_err, &malformed) {
+				t.Fatalf("unexpected error %v", whole_err)
+			}
+			if malformed.Size >= MessageHeaderSize && malformed.Size%4 == 0 {
+				t.Fatalf("rejected a valid size %d", malformed.Size)
+			}
+		}
+		if len(whole) != len(chunked) {
+			t.Fatalf("%d messages in one call, %d in chunks", len(whole), len(chunked))
+		}
+		for i := range whole {
+			a, b := whole[i], chunked[i]
+			if a.ObjectID != b.ObjectID || a.Opcode != b.Opcode || a.Size != b.Size || !bytes.Equal(a.Data, b.Data) {
+				t.Fatalf("message %d differs: %+v and %+v", i, a, b)
+			}
+			if a.Size < MessageHeaderSize || a.Size%4 != 0 || int(a.Size)-MessageHeaderSize != len(a.Data) {
+				t.Fatalf("message %d has size %d and %d bytes of data", i, a.Size, len(a.Data))
+			}
+		}
+	})
+}
+
+func BenchmarkMessageDecoderConsume(b *testing.B) {
+	/**
+	 * A read full of small requests, like a frame's
+	 * worth of attach, damage, frame and commit
+	 */
+	buf := make([]byte, 0, 64*1024)
+	for len(buf)+24 <= cap(buf) {
+		buf = appendTestMessage(buf, 3, 1, 24, 16)
+		buf = appendTestMessage(buf, 3, 6, 8, 0)
+	}
+	d := MakeMessageDecoder()
+	b.SetBytes(int64(len(buf)))
+	b.ReportAllocs()
+	for b.Loop() {
+		/**
+		 * Cut in two, so messages carry across reads
+		 */
+		half := len(buf)/2 + 3
+		if _, err := d.Consume(buf[:half]); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := d.Consume(buf[half:]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
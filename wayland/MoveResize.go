@@ -0,0 +1,392 @@
+package wayland
+
+import (
+	"sync"
+
+	"github.com/mmulet/term.everything/wayland/protocols"
+)
+
+/**
+ * When false every toplevel is maximized and fullscreen,
+ * when true they get the size they ask for, can overlap,
+ * and can be moved and resized with the mouse.
+ */
+var FloatingWindows = false
+
+/**
+ * New floating windows are cascaded this far
+ * apart, so they don't all land on top of each other.
+ */
+const CascadeStep = 32
+
+/**
+ * A moved window keeps at least this much of
+ * itself on screen, so it can be grabbed again.
+ */
+const MinVisibleWindowPart = 32
+
+/**
+ * An interactive move or resize, started by xdg_toplevel.move
+ * or resize (a client side title bar or border being dragged).
+ * It follows the pointer until the button is released.
+ */
+type MoveResizeState struct {
+	Access sync.Mutex
+
+	Active   bool
+	Client   protocols.ClientState
+	Toplevel protocols.ObjectID[protocols.XdgToplevel]
+	/**
+	 * none for a move
+	 */
+	Edges protocols.XdgToplevelResizeEdge_enum
+
+	StartPointerX float32
+	StartPointerY float32
+	/**
+	 * The window geometry when it started, in desktop coordinates
+	 */
+	Start Rect
+
+	/**
+	 * Size in the last configure we sent
+	 */
+	LastWidth  int32
+	LastHeight int32
+
+	/**
+	 * How many floating windows have been placed
+	 */
+	placed int32
+}
+
+var MoveResize MoveResizeState
+
+/**
+ * Where the next floating window goes
+ */
+func (m *MoveResizeState) NextWindowPosition() (x, y int32) {
+	m.Access.Lock()
+	defer m.Access.Unlock()
+	n := m.placed
+	m.placed++
+	spanX := max(int32(VirtualMonitorSize.Width)/2, CascadeStep)
+	spanY := max(int32(VirtualMonitorSize.Height)/2, CascadeStep)
+	return (n * CascadeStep) % spanX, (n * CascadeStep) % spanY
+}
+
+func (m *MoveResizeState) IsActive() bool {
+	m.Access.Lock()
+	defer m.Access.Unlock()
+	return m.Active
+}
+
+/**
+ * Start moving (edges none) or resizing the toplevel,
+ * if one of its surfaces has the pointer and a button
+ * is held. s must be locked.
+ */
+func (m *MoveResizeState) Begin(
+	s protocols.ClientState,
+	toplevel_id protocols.ObjectID[protocols.XdgToplevel],
+	edges protocols.XdgToplevelResizeEdge_enum,
+) {
+	if !FloatingWindows {
+		return
+	}
+	Pointer.Access.Lock()
+	held := len(Pointer.ButtonsHeld) > 0 && Pointer.Focus != nil && Pointer.Focus.Client == s
+	pointerX, pointerY := Pointer.WindowX, Pointer.WindowY
+	Pointer.Access.Unlock()
+	if !held {
+		return
+	}
+	top := GetXdgToplevelObject(s, toplevel_id)
+	if top == nil || top.Maximized || top.Fullscreen {
+		return
+	}
+	geometry := ToplevelGeometry(s, toplevel_id)
+
+	m.Access.Lock()
+	defer m.Access.Unlock()
+	m.Active = true
+	m.Client = s
+	m.Toplevel = toplevel_id
+	m.Edges = edges
+	m.StartPointerX = pointerX
+	m.StartPointerY = pointerY
+	m.Start = Rect{X: top.X, Y: top.Y, Width: geometry.Width, Height: geometry.Height}
+	m.LastWidth = geometry.Width
+	m.LastHeight = geometry.Height
+}
+
+/**
+ * The pointer moved to x, y. The clients must be locked.
+ */
+func (m *MoveResizeState) Motion(x, y float32) {
+	m.Access.Lock()
+	defer m.Access.Unlock()
+	if !m.Active {
+		return
+	}
+	top := GetXdgToplevelObject(m.Client, m.Toplevel)
+	if top == nil {
+		m.Active = false
+		return
+	}
+	dx := int32(x - m.StartPointerX)
+	dy := int32(y - m.StartPointerY)
+
+	if m.Edges == protocols.XdgToplevelResizeEdge_enum_none {
+		top.X = min(max(m.Start.X+dx, MinVisibleWindowPart-m.Start.Width), int32(VirtualMonitorSize.Width)-MinVisibleWindowPart)
+		top.Y = min(max(m.Start.Y+dy, 0), int32(VirtualMonitorSize.Height)-MinVisibleWindowPart)
+		placeToplevel(m.Client, m.Toplevel)
+		return
+	}
+
+	width, height := m.Start.Width, m.Start.Height
+	if m.Edges&protocols.XdgToplevelResizeEdge_enum_left != 0 {
+		width -= dx
+	}
+	if m.Edges&protocols.XdgToplevelResizeEdge_enum_right != 0 {
+		width += dx
+	}
+	if m.Edges&protocols.XdgToplevelResizeEdge_enum_top != 0 {
+		height -= dy
+	}
+	if m.Edges&protocols.XdgToplevelResizeEdge_enum_bottom != 0 {
+		height += dy
+	}
+	width, height = top.ConstrainSize(width, height)
+	if width == m.LastWidth && height == m.LastHeight {
+		return
+	}
+	m.LastWidth, m.LastHeight = width, height
+	ConfigureToplevel(m.Client, m.Toplevel, width, height, protocols.XdgToplevelState_enum_resizing)
+}
+
+/**
+ * The button was released. The clients must be locked.
+ */
+func (m *MoveResizeState) End() {
+	m.Access.Lock()
+	defer m.Access.Unlock()
+	if !m.Active {
+		return
+	}
+	m.Active = false
+	if m.Edges == protocols.XdgToplevelResizeEdge_enum_none {
+		return
+	}
+	/**
+	 * The last commits come after this, anchor
+	 * to the size they should have.
+	 */
+	if top := GetXdgToplevelObject(m.Client, m.Toplevel); top != nil {
+		if m.Edges&protocols.XdgToplevelResizeEdge_enum_left != 0 {
+			top.X = m.Start.X + m.Start.Width - m.LastWidth
+		}
+		if m.Edges&protocols.XdgToplevelResizeEdge_enum_top != 0 {
+			top.Y = m.Start.Y + m.Start.Height - m.LastHeight
+		}
+	}
+	/**
+	 * Same size, without the resizing state
+	 */
+	ConfigureToplevel(m.Client, m.Toplevel, m.LastWidth, m.LastHeight)
+}
+
+/**
+ * Resizing from the left or top keeps the opposite
+ * edge where it was, so the position follows the size
+ * the client actually committed. s must be locked.
+ */
+func (m *MoveResizeState) anchor(
+	s protocols.ClientState,
+	toplevel_id protocols.ObjectID[protocols.XdgToplevel],
+	top *XdgToplevel,
+	geometry XdgWindowGeometry,
+) {
+	m.Access.Lock()
+	defer m.Access.Unlock()
+	if !m.Active || m.Client != s || m.Toplevel != toplevel_id {
+		return
+	}
+	if m.Edges&protocols.XdgToplevelResizeEdge_enum_left != 0 {
+		top.X = m.Start.X + m.Start.Width - geometry.Width
+	}
+	if m.Edges&protocols.XdgToplevelResizeEdge_enum_top != 0 {
+		top.Y = m.Start.Y + m.Start.Height - geometry.Height
+	}
+}
+
+func (m *MoveResizeState) ToplevelDestroyed(s protocols.ClientState, toplevel_id protocols.ObjectID[protocols.XdgToplevel]) {
+	m.Access.Lock()
+	defer m.Access.Unlock()
+	if m.Active && m.Client == s && m.Toplevel == toplevel_id {
+		m.Active = false
+	}
+}
+
+func (m *MoveResizeState) RemoveClient(s protocols.ClientState) {
+	m.Access.Lock()
+	defer m.Access.Unlock()
+	if m.Active && m.Client == s {
+		m.Active = false
+	}
+}
+
+/**
+ * The toplevel's window geometry, or its whole
+ * surface if the client never set one.
+ */
+func ToplevelGeometry(s protocols.ClientState, toplevel_id protocols.ObjectID[protocols.XdgToplevel]) XdgWindowGeometry {
+	return WindowGeometry(s, GetSurfaceFromRole(s, toplevel_id))
+}
+
+/**
+ * Like ToplevelGeometry, for any xdg_surface's wl_surface
+ */
+func WindowGeometry(s protocols.ClientState, surface *WlSurface) XdgWindowGeometry {
+	if surface == nil {
+		return XdgWindowGeometry{}
+	}
+	if surface.XdgSurfaceState != nil {
+		xdg_surface := GetXdgSurfaceObject(s, *surface.XdgSurfaceState)
+		if xdg_surface != nil && xdg_surface.WindowGeometry.Width > 0 && xdg_surface.WindowGeometry.Height > 0 {
+			return xdg_surface.WindowGeometry
+		}
+	}
+	if surface.Texture == nil {
+		return XdgWindowGeometry{}
+	}
+	return XdgWindowGeometry{
+		Width:  int32(surface.Texture.Width),
+		Height: int32(surface.Texture.Height),
+	}
+}
+
+/**
+ * Move the toplevel's surface to where the toplevel is now,
+ * its window geometry starts at the toplevel's X, Y if it
+ * is floating. s must be locked.
+ */
+func PlaceToplevel(s protocols.ClientState, toplevel_id protocols.ObjectID[protocols.XdgToplevel]) {
+	if top := GetXdgToplevelObject(s, toplevel_id); top != nil {
+		MoveResize.anchor(s, toplevel_id, top, ToplevelGeometry(s, toplevel_id))
+	}
+	placeToplevel(s, toplevel_id)
+}
+
+/**
+ * PlaceToplevel without anchoring, for
+ * when MoveResize is already locked.
+ */
+func placeToplevel(s protocols.ClientState, toplevel_id protocols.ObjectID[protocols.XdgToplevel]) {
+	surface := GetSurfaceFromRole(s, toplevel_id)
+	if surface == nil {
+		return
+	}
+	top := GetXdgToplevelObject(s, toplevel_id)
+	if !FloatingWindows || top == nil || top.Maximized || top.Fullscreen {
+		surface.Position.X, surface.Position.Y = surface.Offset.X, surface.Offset.Y
+		return
+	}
+	geometry := ToplevelGeometry(s, toplevel_id)
+	surface.Position.X = top.X - geometry.X
+	surface.Position.Y = top.Y - geometry.Y
+}
+
+/**
+ * Send xdg_toplevel.configure and xdg_surface.configure
+ * without waiting for the ack. A size of 0 lets the
+ * client choose. s must be locked.
+ */
+func ConfigureToplevel(
+	s protocols.ClientState,
+	toplevel_id protocols.ObjectID[protocols.XdgToplevel],
+	width, height int32,
+	states ...protocols.XdgToplevelState_enum,
+) {
+	surface_id := GetSurfaceIDFromRole(s, toplevel_id)
+	activated := surface_id != nil && KeyboardFocus.IsFocused(s, *surface_id)
+	configureToplevel(s, toplevel_id, activated, width, height, states...)
+}
+
+/**
+ * The size and states the toplevel has now, to
+ * configure it again with. s must be locked.
+ */
+func ToplevelConfiguration(
+	s protocols.ClientState,
+	toplevel_id protocols.ObjectID[protocols.XdgToplevel],
+) (width, height int32, states []protocols.XdgToplevelState_enum) {
+	top := GetXdgToplevelObject(s, toplevel_id)
+	if top == nil {
+		return 0, 0, nil
+	}
+	if top.Maximized {
+		states = append(states, protocols.XdgToplevelState_enum_maximized)
+	}
+	if top.Fullscreen {
+		states = append(states, protocols.XdgToplevelState_enum_fullscreen)
+	}
+	if top.Maximized || top.Fullscreen || !FloatingWindows {
+		return int32(VirtualMonitorSize.Width), int32(VirtualMonitorSize.Height), states
+	}
+	geometry := ToplevelGeometry(s, toplevel_id)
+	return geometry.Width, geometry.Height, states
+}
+
+/**
+ * Keyboard focus entered or left the surface, if it is a
+ * toplevel that was configured before, tell it it is (or
+ * isn't) activated. s and KeyboardFocus must be locked.
+ */
+func toplevelActivated(
+	s protocols.ClientState,
+	surface_id protocols.ObjectID[protocols.WlSurface],
+	activated bool,
+) {
+	surface := GetWlSurfaceObject(s, surface_id)
+	if surface == nil || surface.XdgSurfaceState == nil {
+		return
+	}
+	role, ok := surface.Role.(*SurfaceRoleXdgToplevel)
+	if !ok || role.Data == nil {
+		return
+	}
+	xdg_surface := GetXdgSurfaceObject(s, *surface.XdgSurfaceState)
+	if xdg_surface == nil || xdg_surface.LatestSerial == 0 {
+		/**
+		 * Its first configure is still to come
+		 */
+		return
+	}
+	width, height, states := ToplevelConfiguration(s, *role.Data)
+	configureToplevel(s, *role.Data, activated, width, height, states...)
+}
+
+func configureToplevel(
+	s protocols.ClientState,
+	toplevel_id protocols.ObjectID[protocols.XdgToplevel],
+	activated bool,
+	width, height int32,
+	states ...protocols.XdgToplevelState_enum,
+) {
+	surface := GetSurfaceFromRole(s, toplevel_id)
+	if surface == nil || surface.XdgSurfaceState == nil {
+		return
+	}
+	xdg_surface := GetXdgSurfaceObject(s, *surface.XdgSurfaceState)
+	if xdg_surface == nil {
+		return
+	}
+	if activated {
+		states = append(states, protocols.XdgToplevelState_enum_activated)
+	}
+	protocols.XdgToplevel_configure(s, toplevel_id, width, height, ToBytes(states))
+	serial := xdg_surface.LatestSerial
+	xdg_surface.LatestSerial++
+	protocols.XdgSurface_configure(s, xdg_surface.XdgSurfaceID, serial)
+}
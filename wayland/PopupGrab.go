@@ -0,0 +1,226 @@
+package wayland
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/mmulet/term.everything/wayland/protocols"
+)
+
+/**
+ * Popups that took an explicit grab (menus, combo box
+ * dropdowns). Only one client has a grab at a time, its
+ * popups are nested, each one's parent is the one before it.
+ * A click outside of them, or Esc, dismisses them.
+ */
+type PopupGrabState struct {
+	Access sync.Mutex
+
+	/**
+	 * nil when nothing is grabbing
+	 */
+	Client protocols.ClientState
+	/**
+	 * The topmost popup is last
+	 */
+	Stack []protocols.ObjectID[protocols.XdgPopup]
+}
+
+var PopupGrab PopupGrabState
+
+/**
+ * Push popup_id on the stack. parent_popup is the popup it
+ * was opened from, or nil if it was opened from a toplevel.
+ * s must be locked.
+ */
+func (g *PopupGrabState) Grab(
+	s protocols.ClientState,
+	popup_id protocols.ObjectID[protocols.XdgPopup],
+	parent_popup *protocols.ObjectID[protocols.XdgPopup],
+) {
+	g.Access.Lock()
+	defer g.Access.Unlock()
+	if g.Client != nil && g.Client != s {
+		/**
+		 * Another client's menu is open, it closes,
+		 * like it would on a click outside of it.
+		 */
+		other, stack := g.Client, g.Stack
+		g.Client, g.Stack = nil, nil
+		other.QueueTask(func() {
+			for i := len(stack) - 1; i >= 0; i-- {
+				dismissPopup(other, stack[i])
+			}
+		})
+	}
+	above := 0
+	if parent_popup != nil {
+		/**
+		 * Opening a submenu closes the
+		 * submenus the parent already had open.
+		 */
+		above = slices.Index(g.Stack, *parent_popup) + 1
+	}
+	g.dismiss(above)
+	g.Client = s
+	g.Stack = append(g.Stack, popup_id)
+}
+
+func (g *PopupGrabState) IsActive() bool {
+	g.Access.Lock()
+	defer g.Access.Unlock()
+	return g.Client != nil
+}
+
+/**
+ * Is surface_id (or the surface it is a subsurface of)
+ * one of the grabbed popups. s must be locked.
+ */
+func (g *PopupGrabState) Contains(s protocols.ClientState, surface_id protocols.ObjectID[protocols.WlSurface]) bool {
+	popup_id := PopupOfSurface(s, surface_id)
+	if popup_id == nil {
+		return false
+	}
+	g.Access.Lock()
+	defer g.Access.Unlock()
+	return g.Client == s && slices.Contains(g.Stack, *popup_id)
+}
+
+/**
+ * While a grab is active only the grabbing client
+ * gets pointer focus, hit is nil if it is another's.
+ */
+func (g *PopupGrabState) Filter(hit *SurfaceHit) *SurfaceHit {
+	g.Access.Lock()
+	defer g.Access.Unlock()
+	if hit == nil || g.Client == nil || protocols.ClientState(hit.Client) == g.Client {
+		return hit
+	}
+	return nil
+}
+
+/**
+ * The popup's surface got a buffer. If it is the topmost
+ * grabbed popup, it gets keyboard focus. s must be locked.
+ */
+func (g *PopupGrabState) Committed(s protocols.ClientState, popup_id protocols.ObjectID[protocols.XdgPopup]) {
+	g.Access.Lock()
+	defer g.Access.Unlock()
+	if g.Client != s || len(g.Stack) == 0 || g.Stack[len(g.Stack)-1] != popup_id {
+		return
+	}
+	if surface_id := GetSurfaceIDFromRole(s, popup_id); surface_id != nil {
+		KeyboardFocus.Focus(s, *surface_id)
+	}
+}
+
+/**
+ * Dismiss every grabbed popup, topmost first.
+ * The grabbing client must be locked.
+ */
+func (g *PopupGrabState) DismissAll() {
+	g.Access.Lock()
+	defer g.Access.Unlock()
+	g.dismiss(0)
+}
+
+/**
+ * Dismiss only the topmost popup, like Esc
+ * closing a submenu. The grabbing client must be locked.
+ */
+func (g *PopupGrabState) DismissTopmost() {
+	g.Access.Lock()
+	defer g.Access.Unlock()
+	g.dismiss(len(g.Stack) - 1)
+}
+
+/**
+ * Dismiss the popups from index up, topmost first,
+ * so children are always done before their parents.
+ */
+func (g *PopupGrabState) dismiss(index int) {
+	index = max(index, 0)
+	for i := len(g.Stack) - 1; i >= index; i-- {
+		dismissPopup(g.Client, g.Stack[i])
+	}
+	g.Stack = g.Stack[:min(index, len(g.Stack))]
+	if len(g.Stack) == 0 {
+		g.Client = nil
+	}
+}
+
+/**
+ * The client destroyed popup_id. It should have been the
+ * topmost, if not the ones above it are dismissed too.
+ * s must be locked.
+ */
+func (g *PopupGrabState) PopupDestroyed(s protocols.ClientState, popup_id protocols.ObjectID[protocols.XdgPopup]) {
+	g.Access.Lock()
+	defer g.Access.Unlock()
+	if g.Client != s {
+		return
+	}
+	i := slices.Index(g.Stack, popup_id)
+	if i == -1 {
+		return
+	}
+	g.dismiss(i + 1)
+	g.Stack = g.Stack[:i]
+	if len(g.Stack) == 0 {
+		g.Client = nil
+	}
+}
+
+func (g *PopupGrabState) RemoveClient(s protocols.ClientState) {
+	g.Access.Lock()
+	defer g.Access.Unlock()
+	if g.Client == s {
+		g.Client = nil
+		g.Stack = nil
+	}
+}
+
+/**
+ * Send popup_done, the popup doesn't get
+ * any more input. s must be locked.
+ */
+func dismissPopup(s protocols.ClientState, popup_id protocols.ObjectID[protocols.XdgPopup]) {
+	popup := GetXdgPopupObject(s, popup_id)
+	if popup == nil {
+		return
+	}
+	popup.Dismissed = true
+	if surface_id := GetSurfaceIDFromRole(s, popup_id); surface_id != nil {
+		KeyboardFocus.SurfaceUnmapped(s, *surface_id)
+	}
+	protocols.XdgPopup_popup_done(s, popup_id)
+}
+
+/**
+ * The popup surface_id is the wl_surface of, following
+ * subsurfaces up to their parent, or nil. s must be locked.
+ */
+func PopupOfSurface(s protocols.ClientState, surface_id protocols.ObjectID[protocols.WlSurface]) *protocols.ObjectID[protocols.XdgPopup] {
+	for range 64 {
+		surface := GetWlSurfaceObject(s, surface_id)
+		if surface == nil {
+			return nil
+		}
+		switch role := surface.Role.(type) {
+		case *SurfaceRoleXdgPopup:
+			return role.Data
+		case *SurfaceRoleSubSurface:
+			if role.Data == nil {
+				return nil
+			}
+			subsurface := GetWlSubsurfaceObject(s, *role.Data)
+			if subsurface == nil {
+				return nil
+			}
+			surface_id = subsurface.Parent
+		default:
+			return nil
+		}
+	}
+	return nil
+}
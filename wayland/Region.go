@@ -0,0 +1,154 @@
+package wayland
+
+/**
+ * A set of non overlapping rectangles,
+ * what a wl_region describes.
+ */
+type Region struct {
+	Rects []Rect
+}
+
+/**
+ * Clients damage with INT32_MAX sized rects to mean
+ * "everything", keep them small enough that adding
+ * position and size together can't overflow.
+ */
+const MaxRectSize = 1 << 16
+
+func ClampRect(r Rect) Rect {
+	x0 := min(max(r.X, -MaxRectSize), MaxRectSize)
+	y0 := min(max(r.Y, -MaxRectSize), MaxRectSize)
+	x1 := min(max(int64(r.X)+int64(r.Width), -MaxRectSize), MaxRectSize)
+	y1 := min(max(int64(r.Y)+int64(r.Height), -MaxRectSize), MaxRectSize)
+	return Rect{X: x0, Y: y0, Width: int32(x1) - x0, Height: int32(y1) - y0}
+}
+
+func ScaleRect(r Rect, scale int32) Rect {
+	return Rect{X: r.X * scale, Y: r.Y * scale, Width: r.Width * scale, Height: r.Height * scale}
+}
+
+func (r Rect) IsEmpty() bool {
+	return r.Width <= 0 || r.Height <= 0
+}
+
+func (r Rect) Contains(x, y float32) bool {
+	return x >= float32(r.X) && y >= float32(r.Y) &&
+		x < float32(r.X+r.Width) && y < float32(r.Y+r.Height)
+}
+
+func (r Rect) Intersect(o Rect) Rect {
+	x0 := max(r.X, o.X)
+	y0 := max(r.Y, o.Y)
+	x1 := min(r.X+r.Width, o.X+o.Width)
+	y1 := min(r.Y+r.Height, o.Y+o.Height)
+	if x1 <= x0 || y1 <= y0 {
+		return Rect{}
+	}
+	return Rect{X: x0, Y: y0, Width: x1 - x0, Height: y1 - y0}
+}
+
+/**
+ * The parts of r not covered by o, at most 4 rectangles:
+ * a full width band above and below o, and what is
+ * left to the left and right of o in between.
+ */
+func (r Rect) Subtract(o Rect) []Rect {
+	inter := r.Intersect(o)
+	if inter.IsEmpty() {
+		return []Rect{r}
+	}
+	out := make([]Rect, 0, 4)
+	if inter.Y > r.Y {
+		out = append(out, Rect{X: r.X, Y: r.Y, Width: r.Width, Height: inter.Y - r.Y})
+	}
+	if bottom := r.Y + r.Height; inter.Y+inter.Height < bottom {
+		out = append(out, Rect{X: r.X, Y: inter.Y + inter.Height, Width: r.Width, Height: bottom - (inter.Y + inter.Height)})
+	}
+	if inter.X > r.X {
+		out = append(out, Rect{X: r.X, Y: inter.Y, Width: inter.X - r.X, Height: inter.Height})
+	}
+	if right := r.X + r.Width; inter.X+inter.Width < right {
+		out = append(out, Rect{X: inter.X + inter.Width, Y: inter.Y, Width: right - (inter.X + inter.Width), Height: inter.Height})
+	}
+	return out
+}
+
+func (g *Region) Add(r Rect) {
+	if r.IsEmpty() {
+		return
+	}
+	/**
+	 * Remove the overlap first, so the
+	 * rectangles never overlap each other.
+	 */
+	g.Subtract(r)
+	g.Rects = append(g.Rects, r)
+}
+
+func (g *Region) Subtract(r Rect) {
+	if r.IsEmpty() {
+		return
+	}
+	rects := make([]Rect, 0, len(g.Rects))
+	for _, it := range g.Rects {
+		rects = append(rects, it.Subtract(r)...)
+	}
+	g.Rects = rects
+}
+
+func (g *Region) Union(o *Region) {
+	if o == nil {
+		return
+	}
+	for _, r := range o.Rects {
+		g.Add(r)
+	}
+}
+
+func (g *Region) Contains(x, y float32) bool {
+	for _, r := range g.Rects {
+		if r.Contains(x, y) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * Is all of r inside the region
+ */
+func (g *Region) ContainsRect(r Rect) bool {
+	left := []Rect{r}
+	for _, it := range g.Rects {
+		next := make([]Rect, 0, len(left))
+		for _, l := range left {
+			next = append(next, l.Subtract(it)...)
+		}
+		left = next
+		if len(left) == 0 {
+			return true
+		}
+	}
+	return r.IsEmpty()
+}
+
+func (g *Region) IsEmpty() bool {
+	return len(g.Rects) == 0
+}
+
+/**
+ * The region moved by x, y
+ */
+func (g *Region) Translate(x, y int32) *Region {
+	out := &Region{Rects: make([]Rect, len(g.Rects))}
+	for i, r := range g.Rects {
+		r.X += x
+		r.Y += y
+		out.Rects[i] = r
+	}
+	return out
+}
+
+func (g *Region) Clone() *Region {
+	return g.Translate(0, 0)
+}
@@ -0,0 +1,372 @@
+package wayland
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mmulet/term.everything/wayland/protocols"
+)
+
+/**
+ * Anything that can provide the contents of a selection,
+ * either a client's wl_data_source or the host terminal's
+ * clipboard.
+ */
+type SelectionSource interface {
+	GetMimeTypes() []string
+	/**
+	 * Write the selection as mime_type into fd.
+	 * fd belongs to the source after this call,
+	 * it is responsible for closing it.
+	 */
+	SendTo(mime_type string, fd protocols.FileDescriptor)
+	/**
+	 * The source is no longer the selection
+	 */
+	Cancel()
+	/**
+	 * The client that owns this source, or nil
+	 * if it came from the host terminal.
+	 */
+	Owner() protocols.ClientState
+}
+
+/**
+ * A per client object that should be told about the
+ * current selection, like a wl_data_device.
+ */
+type SelectionDevice interface {
+	/**
+	 * Called with the device's client locked.
+	 * source may be nil, meaning there is no selection.
+	 */
+	OfferSelection(s protocols.ClientState, source SelectionSource)
+}
+
+/**
+ * The text that should be placed on the host terminal's
+ * clipboard, Name is the OSC 52 selection name ("c" or "p")
+ */
+type HostSelectionCopy struct {
+	Name string
+	Data []byte
+}
+
+/**
+ * Selections copied by wayland clients, read by
+ * termeverything and written out with OSC 52.
+ */
+var HostSelectionCopies = make(chan HostSelectionCopy, 8)
+
+/**
+ * Text mime types we understand, in order of preference
+ */
+var TextMimeTypes = []string{
+	"text/plain;charset=utf-8",
+	"text/plain",
+	"UTF8_STRING",
+	"TEXT",
+	"STRING",
+}
+
+/**
+ * Don't copy anything bigger than this to the host,
+ * most terminals cap OSC 52 well below this anyway.
+ */
+const MaxHostSelectionCopySize = 1 << 20
+
+/**
+ * How long to wait for a client to write out
+ * its selection before giving up.
+ */
+const HostSelectionCopyTimeout = 5 * time.Second
+
+type SelectionState struct {
+	Access sync.Mutex
+
+	/**
+	 * OSC 52 selection name of the host clipboard
+	 * this selection is mirrored to.
+	 */
+	HostName string
+	/**
+	 * Copy selections made by clients to the host terminal
+	 */
+	MirrorToHost bool
+
+	Source SelectionSource
+
+	/**
+	 * The last text exchanged with the host terminal,
+	 * so we don't bounce the same text back and forth.
+	 */
+	HostData []byte
+
+	Devices map[protocols.ClientState]map[protocols.AnyObjectID]SelectionDevice
+
+	/**
+	 * The selection each client's devices were last
+	 * offered. Only the client with keyboard focus is
+	 * offered the selection, the others can't read it.
+	 */
+	Offered map[protocols.ClientState]SelectionSource
+}
+
+func MakeSelectionState(hostName string, mirrorToHost bool) *SelectionState {
+	return &SelectionState{
+		HostName:     hostName,
+		MirrorToHost: mirrorToHost,
+		Devices:      make(map[protocols.ClientState]map[protocols.AnyObjectID]SelectionDevice),
+		Offered:      make(map[protocols.ClientState]SelectionSource),
+	}
+}
+
+var Clipboard = MakeSelectionState("c", true)
+
+/**
+ * The middle click selection, only mirrored to the
+ * host when asked for, since it changes every time
+ * text is selected.
+ */
+var PrimarySelection = MakeSelectionState("p", false)
+
+/**
+ * Replace the selection, cancel the old source and offer
+ * the new one to the client with keyboard focus, the others
+ * get it when they get focus (see FocusEntered).
+ * source may be nil to clear the selection.
+ */
+func (st *SelectionState) SetSelection(source SelectionSource) {
+	st.Access.Lock()
+	old := st.Source
+	if old == source {
+		st.Access.Unlock()
+		return
+	}
+	st.Source = source
+	st.Access.Unlock()
+
+	if old != nil {
+		old.Cancel()
+	}
+
+	if focused := KeyboardFocus.FocusedClient(); focused != nil {
+		focused.QueueTask(func() {
+			st.offerToClient(focused, source)
+		})
+	}
+
+	if st.MirrorToHost && source != nil && source.Owner() != nil {
+		st.CopyToHost(source)
+	}
+}
+
+func (st *SelectionState) offerToClient(s protocols.ClientState, source SelectionSource) {
+	st.Access.Lock()
+	if st.Source != source {
+		/**
+		 * The selection changed again before this
+		 * task ran, the newer task will offer it.
+		 */
+		st.Access.Unlock()
+		return
+	}
+	devices := st.offerTo(s, source)
+	st.Access.Unlock()
+	for _, device := range devices {
+		device.OfferSelection(s, source)
+	}
+}
+
+/**
+ * Keyboard focus entered one of s's surfaces, offer it
+ * the selection if it doesn't have it yet. s must be locked.
+ */
+func (st *SelectionState) FocusEntered(s protocols.ClientState) {
+	st.Access.Lock()
+	source := st.Source
+	if st.Offered[s] == source {
+		st.Access.Unlock()
+		return
+	}
+	devices := st.offerTo(s, source)
+	st.Access.Unlock()
+	for _, device := range devices {
+		device.OfferSelection(s, source)
+	}
+}
+
+/**
+ * The devices to offer source to, noting that s has been
+ * offered it. st must be locked, offer after unlocking.
+ */
+func (st *SelectionState) offerTo(s protocols.ClientState, source SelectionSource) []SelectionDevice {
+	devices := make([]SelectionDevice, 0, len(st.Devices[s]))
+	for _, device := range st.Devices[s] {
+		devices = append(devices, device)
+	}
+	if len(devices) > 0 {
+		st.Offered[s] = source
+	}
+	return devices
+}
+
+/**
+ * Register a device, and immediately offer it the current
+ * selection if its client has keyboard focus. Call from
+ * the device client's request handler.
+ */
+func (st *SelectionState) AddDevice(s protocols.ClientState, id protocols.AnyObjectID, device SelectionDevice) {
+	focused := KeyboardFocus.FocusedClient() == s
+	st.Access.Lock()
+	devices, ok := st.Devices[s]
+	if !ok {
+		devices = make(map[protocols.AnyObjectID]SelectionDevice)
+		st.Devices[s] = devices
+	}
+	devices[id] = device
+	source := st.Source
+	if focused {
+		st.Offered[s] = source
+	}
+	st.Access.Unlock()
+
+	if focused && source != nil {
+		device.OfferSelection(s, source)
+	}
+}
+
+func (st *SelectionState) RemoveDevice(s protocols.ClientState, id protocols.AnyObjectID) {
+	st.Access.Lock()
+	defer st.Access.Unlock()
+	devices, ok := st.Devices[s]
+	if !ok {
+		return
+	}
+	delete(devices, id)
+	if len(devices) == 0 {
+		delete(st.Devices, s)
+		delete(st.Offered, s)
+	}
+}
+
+/**
+ * Clear the selection if source is still the selection,
+ * for when the source is destroyed.
+ */
+func (st *SelectionState) SourceDestroyed(source SelectionSource) {
+	st.Access.Lock()
+	isCurrent := st.Source == source
+	st.Access.Unlock()
+	if isCurrent {
+		st.SetSelection(nil)
+	}
+}
+
+/**
+ * Make text from the host terminal the selection
+ */
+func (st *SelectionState) SetHostSelection(data []byte) {
+	st.Access.Lock()
+	if st.Source != nil && bytes.Equal(st.HostData, data) {
+		st.Access.Unlock()
+		return
+	}
+	st.HostData = data
+	st.Access.Unlock()
+	st.SetSelection(MakeHostSelectionSource(data))
+}
+
+/**
+ * Forget everything about a client that has disconnected
+ */
+func (st *SelectionState) RemoveClient(s protocols.ClientState) {
+	st.Access.Lock()
+	delete(st.Devices, s)
+	delete(st.Offered, s)
+	var ownedSource SelectionSource
+	if st.Source != nil && st.Source.Owner() == s {
+		ownedSource = st.Source
+	}
+	st.Access.Unlock()
+	if ownedSource != nil {
+		st.SourceDestroyed(ownedSource)
+	}
+}
+
+/**
+ * Read the source as text and hand it to the host terminal
+ * through HostSelectionCopies.
+ */
+func (st *SelectionState) CopyToHost(source SelectionSource) {
+	mimeType := PreferredTextMimeType(source.GetMimeTypes())
+	if mimeType == "" {
+		return
+	}
+	var fds [2]int
+	if err := syscall.Pipe2(fds[:], syscall.O_CLOEXEC); err != nil {
+		log.Printf("CopyToHost: failed to create pipe: %v", err)
+		return
+	}
+	/**
+	 * Only the read end is non blocking, so the
+	 * runtime poller can enforce a deadline on it.
+	 */
+	if err := syscall.SetNonblock(fds[0], true); err != nil {
+		log.Printf("CopyToHost: failed to set pipe non blocking: %v", err)
+	}
+	source.SendTo(mimeType, protocols.FileDescriptor(fds[1]))
+
+	go func() {
+		reader := os.NewFile(uintptr(fds[0]), "selection")
+		defer reader.Close()
+		reader.SetReadDeadline(time.Now().Add(HostSelectionCopyTimeout))
+		data, err := io.ReadAll(io.LimitReader(reader, MaxHostSelectionCopySize+1))
+		if err != nil {
+			log.Printf("CopyToHost: failed to read selection: %v", err)
+			return
+		}
+		if len(data) > MaxHostSelectionCopySize {
+			log.Printf("CopyToHost: selection is too large to copy to the host")
+			return
+		}
+		if len(data) == 0 {
+			return
+		}
+		st.Access.Lock()
+		st.HostData = data
+		st.Access.Unlock()
+		select {
+		case HostSelectionCopies <- HostSelectionCopy{Name: st.HostName, Data: data}:
+		default:
+		}
+	}()
+}
+
+func PreferredTextMimeType(mimeTypes []string) string {
+	for _, mimeType := range TextMimeTypes {
+		if slices.Contains(mimeTypes, mimeType) {
+			return mimeType
+		}
+	}
+	return ""
+}
+
+/**
+ * Wraps a Sender so that the file descriptor of the event
+ * is closed on our side once it has been sent to the client.
+ */
+type closeFileDescriptorAfterSend struct {
+	protocols.Sender
+}
+
+func (c closeFileDescriptorAfterSend) Send(ev protocols.OutgoingEvent) {
+	ev.CloseFileDescriptorAfterSend = true
+	c.Sender.Send(ev)
+}
@@ -0,0 +1,15 @@
+package wayland
+
+import "sync/atomic"
+
+var lastSerial atomic.Uint32
+
+/**
+ * Serials for input events (enter, leave, key, button...).
+ * There is one seat, so one counter shared by every client,
+ * that way a serial a client hands back can be matched
+ * against the event that produced it.
+ */
+func NextSerial() uint32 {
+	return lastSerial.Add(1)
+}
@@ -97,3 +97,16 @@ func (r *SurfaceRoleSubSurface) HasData() bool {
 func (r *SurfaceRoleSubSurface) ClearData() {
 	r.Data = nil
 }
+
+/**
+ * The icon of a drag started with wl_data_device.start_drag.
+ * There is no role object, so nothing needs to
+ * be destroyed before the surface.
+ */
+type SurfaceRoleDragIcon struct{}
+
+func (r *SurfaceRoleDragIcon) surface_role() {}
+func (r *SurfaceRoleDragIcon) HasData() bool {
+	return false
+}
+func (r *SurfaceRoleDragIcon) ClearData() {}
@@ -13,9 +13,9 @@ type SurfaceUpdate struct {
 
 	BufferTransform *protocols.WlOutputTransform_enum
 
-	InputRegion *protocols.ObjectID[protocols.WlRegion]
+	InputRegion *RegionUpdate
 
-	OpaqueRegion *protocols.ObjectID[protocols.WlRegion]
+	OpaqueRegion *RegionUpdate
 
 	Buffer *protocols.ObjectID[protocols.WlBuffer]
 
@@ -79,6 +79,15 @@ const (
 	ZOrderTypeBelow ZOrder = iota + 1
 )
 
+/**
+ * set_input_region and set_opaque_region copy the
+ * region when they are called, Region is that copy.
+ * A nil Region means the request was called with null.
+ */
+type RegionUpdate struct {
+	Region *Region
+}
+
 type XWaylandSurfaceV1Serial struct {
 	Low uint32
 	Hi  uint32
@@ -0,0 +1,152 @@
+package wayland
+
+import (
+	"sync"
+
+	"github.com/mmulet/term.everything/wayland/protocols"
+)
+
+/**
+ * The menu xdg_toplevel.show_window_menu asks for (usually
+ * a right click on a client side title bar). There is only
+ * one at a time, the terminal draws it over the desktop.
+ */
+type WindowMenuState struct {
+	Access sync.Mutex
+
+	Open     bool
+	Client   protocols.ClientState
+	Toplevel protocols.ObjectID[protocols.XdgToplevel]
+
+	/**
+	 * Where it was asked for, in desktop coordinates
+	 */
+	X float32
+	Y float32
+
+	/**
+	 * Index of the highlighted entry
+	 */
+	Selected int
+}
+
+var WindowMenu WindowMenuState
+
+type WindowMenuEntry struct {
+	Label  string
+	Action func()
+}
+
+/**
+ * Open the menu for the toplevel at x, y (surface local).
+ * s must be locked.
+ */
+func (m *WindowMenuState) Show(
+	s protocols.ClientState,
+	toplevel_id protocols.ObjectID[protocols.XdgToplevel],
+	x, y int32,
+) {
+	surface := GetSurfaceFromRole(s, toplevel_id)
+	if surface == nil {
+		return
+	}
+	m.Access.Lock()
+	defer m.Access.Unlock()
+	m.Open = true
+	m.Client = s
+	m.Toplevel = toplevel_id
+	m.X = float32(surface.Position.X + x)
+	m.Y = float32(surface.Position.Y + y)
+	m.Selected = 0
+}
+
+func (m *WindowMenuState) IsOpen() bool {
+	m.Access.Lock()
+	defer m.Access.Unlock()
+	return m.Open
+}
+
+func (m *WindowMenuState) Close() {
+	m.Access.Lock()
+	defer m.Access.Unlock()
+	m.Open = false
+}
+
+/**
+ * Where the menu is, and its entries. ok is false if it
+ * isn't open, or its window is gone (which closes it).
+ * The clients must be locked.
+ */
+func (m *WindowMenuState) Entries() (x, y float32, entries []WindowMenuEntry, selected int, ok bool) {
+	m.Access.Lock()
+	defer m.Access.Unlock()
+	if !m.Open {
+		return 0, 0, nil, 0, false
+	}
+	s, id := m.Client, m.Toplevel
+	top := GetXdgToplevelObject(s, id)
+	if top == nil {
+		m.Open = false
+		return 0, 0, nil, 0, false
+	}
+
+	maximize := WindowMenuEntry{Label: "Maximize", Action: func() { top.XdgToplevel_set_maximized(s, id) }}
+	if top.Maximized {
+		maximize = WindowMenuEntry{Label: "Unmaximize", Action: func() { top.XdgToplevel_unset_maximized(s, id) }}
+	}
+	fullscreen := WindowMenuEntry{Label: "Fullscreen", Action: func() { top.XdgToplevel_set_fullscreen(s, id, nil) }}
+	if top.Fullscreen {
+		fullscreen = WindowMenuEntry{Label: "Leave fullscreen", Action: func() { top.XdgToplevel_unset_fullscreen(s, id) }}
+	}
+	alwaysOnTop := "[ ] Always on top"
+	if top.AlwaysOnTop {
+		alwaysOnTop = "[x] Always on top"
+	}
+	entries = []WindowMenuEntry{
+		{Label: "Minimize", Action: func() { top.XdgToplevel_set_minimized(s, id) }},
+		maximize,
+		fullscreen,
+		{Label: alwaysOnTop, Action: func() { top.AlwaysOnTop = !top.AlwaysOnTop }},
+		{Label: "Close", Action: func() { protocols.XdgToplevel_close(s, id) }},
+	}
+	m.Selected = min(max(m.Selected, 0), len(entries)-1)
+	return m.X, m.Y, entries, m.Selected, true
+}
+
+/**
+ * Highlight entry index
+ */
+func (m *WindowMenuState) Select(index int) {
+	m.Access.Lock()
+	defer m.Access.Unlock()
+	m.Selected = index
+}
+
+/**
+ * Close the menu and do what the entry says.
+ * The clients must be locked.
+ */
+func (m *WindowMenuState) Activate(index int) {
+	_, _, entries, _, ok := m.Entries()
+	m.Close()
+	if !ok || index < 0 || index >= len(entries) {
+		return
+	}
+	entries[index].Action()
+}
+
+func (m *WindowMenuState) ToplevelDestroyed(s protocols.ClientState, toplevel_id protocols.ObjectID[protocols.XdgToplevel]) {
+	m.Access.Lock()
+	defer m.Access.Unlock()
+	if m.Open && m.Client == s && m.Toplevel == toplevel_id {
+		m.Open = false
+	}
+}
+
+func (m *WindowMenuState) RemoveClient(s protocols.ClientState) {
+	m.Access.Lock()
+	defer m.Access.Unlock()
+	if m.Open && m.Client == s {
+		m.Open = false
+	}
+}
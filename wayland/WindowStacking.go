@@ -0,0 +1,95 @@
+package wayland
+
+import (
+	"sync/atomic"
+
+	"github.com/mmulet/term.everything/wayland/protocols"
+)
+
+/**
+ * How a window's surfaces are stacked. Always on
+ * top windows go above the rest, then the most
+ * recently raised windows go above older ones.
+ * Popups go above the window they were opened from.
+ */
+type WindowStacking struct {
+	OnTop  bool
+	Raised uint64
+	/**
+	 * How many popups deep, 0 for the window itself
+	 */
+	Popup int
+}
+
+func (a WindowStacking) Below(b WindowStacking) bool {
+	if a.OnTop != b.OnTop {
+		return b.OnTop
+	}
+	if a.Raised != b.Raised {
+		return a.Raised < b.Raised
+	}
+	return a.Popup < b.Popup
+}
+
+var lastRaised atomic.Uint64
+
+/**
+ * Put the window above the others (in its layer)
+ */
+func (t *XdgToplevel) Raise() {
+	t.Raised = lastRaised.Add(1)
+}
+
+/**
+ * The toplevel surface_id is the wl_surface of, or nil.
+ * s must be locked.
+ */
+func ToplevelOfSurface(s protocols.ClientState, surface_id protocols.ObjectID[protocols.WlSurface]) *XdgToplevel {
+	surface := GetWlSurfaceObject(s, surface_id)
+	if surface == nil {
+		return nil
+	}
+	role, ok := surface.Role.(*SurfaceRoleXdgToplevel)
+	if !ok || role.Data == nil {
+		return nil
+	}
+	return GetXdgToplevelObject(s, *role.Data)
+}
+
+/**
+ * Where the surfaces of the window whose root surface is
+ * root_id go, and if the window is minimized (not drawn).
+ * s must be locked.
+ */
+func SurfaceStacking(s protocols.ClientState, root_id protocols.ObjectID[protocols.WlSurface]) (stacking WindowStacking, minimized bool) {
+	/**
+	 * Popups are stacked with the
+	 * toplevel they were opened from.
+	 */
+	for range 64 {
+		popup_id := PopupOfSurface(s, root_id)
+		if popup_id == nil {
+			break
+		}
+		stacking.Popup++
+		popup := GetXdgPopupObject(s, *popup_id)
+		if popup == nil || popup.Parent == nil {
+			break
+		}
+		parent_id := GetSurfaceIDFromRole(s, *popup.Parent)
+		if parent_id == nil {
+			break
+		}
+		root_id = *parent_id
+	}
+	top := ToplevelOfSurface(s, root_id)
+	if top == nil {
+		if x_stacking, ok := XWindows.Stacking(s, root_id); ok {
+			return x_stacking, false
+		}
+		return stacking, false
+	}
+	stacking.OnTop = top.AlwaysOnTop
+	stacking.Raised = top.Raised
+	return stacking, top.Minimized
+}
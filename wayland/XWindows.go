@@ -0,0 +1,348 @@
+package wayland
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+
+	"github.com/mmulet/term.everything/wayland/protocols"
+)
+
+func (serial XWaylandSurfaceV1Serial) Value() uint64 {
+	return uint64(serial.Hi)<<32 | uint64(serial.Low)
+}
+
+/**
+ * A top level X11 window, as the X window manager sees it
+ */
+type XWindow struct {
+	ID uint32
+
+	X, Y          int32
+	Width, Height uint32
+
+	/**
+	 * Menus and tooltips, they place
+	 * themselves and don't take focus.
+	 */
+	OverrideRedirect bool
+
+	/**
+	 * _NET_WM_NAME, or WM_NAME if it doesn't have one
+	 */
+	Title *string
+
+	/**
+	 * The xwayland_surface_v1 serial Xwayland sent
+	 * the window manager for it, 0 until then.
+	 */
+	Serial uint64
+
+	/**
+	 * The wl_surface showing the window, nil until
+	 * the surface is committed with the same serial.
+	 */
+	Surface *FocusedSurface
+
+	/**
+	 * When it was last raised, see WindowStacking
+	 */
+	Raised uint64
+}
+
+/**
+ * What the compositor needs from the X window manager
+ */
+type XWindowManager interface {
+	/**
+	 * Give the window X input focus
+	 */
+	Activate(window uint32)
+}
+
+/**
+ * X windows and the xwayland surfaces showing them.
+ * Xwayland tells the window manager the serial of a window
+ * over X, and sets the same serial on the wl_surface, in
+ * no particular order, they are paired once both have arrived.
+ */
+type XWindowState struct {
+	Access sync.Mutex
+
+	/**
+	 * nil when no window manager is running
+	 */
+	Manager XWindowManager
+
+	Windows map[uint32]*XWindow
+
+	/**
+	 * Surfaces committed with a serial no window has yet
+	 */
+	Unpaired map[uint64]FocusedSurface
+}
+
+var XWindows = XWindowState{
+	Windows:  make(map[uint32]*XWindow),
+	Unpaired: make(map[uint64]FocusedSurface),
+}
+
+/**
+ * A (re)started window manager, the windows
+ * of the one before it are gone with its X server.
+ */
+func (x *XWindowState) SetManager(manager XWindowManager) {
+	x.Access.Lock()
+	defer x.Access.Unlock()
+	x.Manager = manager
+	x.Windows = make(map[uint32]*XWindow)
+}
+
+/**
+ * The window manager's connection closed
+ */
+func (x *XWindowState) RemoveManager(manager XWindowManager) {
+	x.Access.Lock()
+	defer x.Access.Unlock()
+	if x.Manager == manager {
+		x.Manager = nil
+	}
+}
+
+/**
+ * Is the built in window manager running. Without it (like with
+ * --xwayland-wm) nothing tells us where X windows are.
+ */
+func (x *XWindowState) HasManager() bool {
+	x.Access.Lock()
+	defer x.Access.Unlock()
+	return x.Manager != nil
+}
+
+func (x *XWindowState) WindowCreated(window XWindow) {
+	x.Access.Lock()
+	defer x.Access.Unlock()
+	window.Serial = 0
+	window.Surface = nil
+	x.Windows[window.ID] = &window
+}
+
+func (x *XWindowState) WindowDestroyed(id uint32) {
+	x.Access.Lock()
+	defer x.Access.Unlock()
+	delete(x.Windows, id)
+}
+
+/**
+ * The window moved or was resized, the surface follows it
+ */
+func (x *XWindowState) WindowConfigured(id uint32, xPos, yPos int32, width, height uint32) {
+	x.Access.Lock()
+	defer x.Access.Unlock()
+	window, ok := x.Windows[id]
+	if !ok {
+		return
+	}
+	window.X, window.Y = xPos, yPos
+	window.Width, window.Height = width, height
+	if window.Surface != nil {
+		queuePosition(*window.Surface, xPos, yPos)
+	}
+}
+
+func (x *XWindowState) WindowTitle(id uint32, title string) {
+	x.Access.Lock()
+	defer x.Access.Unlock()
+	if window, ok := x.Windows[id]; ok {
+		window.Title = &title
+	}
+}
+
+/**
+ * Xwayland told the window manager the window's serial
+ */
+func (x *XWindowState) WindowSerial(id uint32, serial uint64) {
+	x.Access.Lock()
+	defer x.Access.Unlock()
+	window, ok := x.Windows[id]
+	if !ok {
+		return
+	}
+	/**
+	 * Remapped windows get a new surface with a new serial
+	 */
+	window.Serial = serial
+	window.Surface = nil
+	surface, ok := x.Unpaired[serial]
+	if !ok {
+		return
+	}
+	delete(x.Unpaired, serial)
+	window.Surface = &surface
+	window.Raised = lastRaised.Add(1)
+	/**
+	 * The surface was committed before we knew where
+	 * to put it, show it from its own client's task queue.
+	 */
+	manager := x.Manager
+	windowX, windowY := window.X, window.Y
+	takesFocus := !window.OverrideRedirect
+	surface.Client.QueueTask(func() {
+		wl_surface := GetWlSurfaceObject(surface.Client, surface.SurfaceID)
+		if wl_surface == nil || wl_surface.Texture == nil {
+			return
+		}
+		wl_surface.Position.X = windowX
+		wl_surface.Position.Y = windowY
+		surface.Client.DrawableSurfaces()[surface.SurfaceID] = true
+		if takesFocus && KeyboardFocus.Focus(surface.Client, surface.SurfaceID) && manager != nil {
+			manager.Activate(id)
+		}
+	})
+}
+
+/**
+ * An xwayland surface with serial was committed, s must be locked.
+ * Returns where to draw it, or ok false if its window isn't known
+ * yet, it shouldn't be drawn until WindowSerial pairs them.
+ */
+func (x *XWindowState) SurfaceCommitted(
+	s protocols.ClientState,
+	surface_id protocols.ObjectID[protocols.WlSurface],
+	serial uint64,
+) (xPos, yPos int32, ok bool) {
+	x.Access.Lock()
+	surface := FocusedSurface{Client: s, SurfaceID: surface_id}
+	var window *XWindow
+	for _, it := range x.Windows {
+		if it.Serial == serial {
+			window = it
+			break
+		}
+	}
+	if window == nil {
+		x.Unpaired[serial] = surface
+		x.Access.Unlock()
+		return 0, 0, false
+	}
+	newlyPaired := window.Surface == nil || *window.Surface != surface
+	window.Surface = &surface
+	if newlyPaired {
+		window.Raised = lastRaised.Add(1)
+	}
+	xPos, yPos = window.X, window.Y
+	id := window.ID
+	takesFocus := newlyPaired && !window.OverrideRedirect
+	manager := x.Manager
+	x.Access.Unlock()
+
+	if takesFocus && KeyboardFocus.Focus(s, surface_id) && manager != nil {
+		manager.Activate(id)
+	}
+	return xPos, yPos, true
+}
+
+/**
+ * Raise the X window shown by surface_id, if it shows
+ * one, and tell the window manager to focus it.
+ */
+func (x *XWindowState) Activate(s protocols.ClientState, surface_id protocols.ObjectID[protocols.WlSurface]) {
+	x.Access.Lock()
+	manager := x.Manager
+	window := x.windowOfSurface(s, surface_id)
+	if window == nil {
+		x.Access.Unlock()
+		return
+	}
+	window.Raised = lastRaised.Add(1)
+	id := window.ID
+	x.Access.Unlock()
+	if manager != nil {
+		manager.Activate(id)
+	}
+}
+
+/**
+ * Where the X window shown by surface_id goes, stacked
+ * with the toplevels. ok is false if it shows none.
+ */
+func (x *XWindowState) Stacking(s protocols.ClientState, surface_id protocols.ObjectID[protocols.WlSurface]) (stacking WindowStacking, ok bool) {
+	x.Access.Lock()
+	defer x.Access.Unlock()
+	window := x.windowOfSurface(s, surface_id)
+	if window == nil {
+		return stacking, false
+	}
+	/**
+	 * Menus and tooltips stay above the windows
+	 */
+	stacking.OnTop = window.OverrideRedirect
+	stacking.Raised = window.Raised
+	return stacking, true
+}
+
+func (x *XWindowState) windowOfSurface(s protocols.ClientState, surface_id protocols.ObjectID[protocols.WlSurface]) *XWindow {
+	for _, window := range x.Windows {
+		if window.Surface != nil && window.Surface.Client == s && window.Surface.SurfaceID == surface_id {
+			return window
+		}
+	}
+	return nil
+}
+
+/**
+ * The X windows that are on screen and aren't
+ * menus or tooltips, by id, for the status line.
+ */
+func (x *XWindowState) Listed() []XWindow {
+	x.Access.Lock()
+	defer x.Access.Unlock()
+	windows := make([]XWindow, 0, len(x.Windows))
+	for _, window := range x.Windows {
+		if window.Surface != nil && !window.OverrideRedirect {
+			windows = append(windows, *window)
+		}
+	}
+	slices.SortFunc(windows, func(a, b XWindow) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return windows
+}
+
+func (x *XWindowState) SurfaceDestroyed(s protocols.ClientState, surface_id protocols.ObjectID[protocols.WlSurface]) {
+	x.forget(func(surface FocusedSurface) bool {
+		return surface.Client == s && surface.SurfaceID == surface_id
+	})
+}
+
+func (x *XWindowState) RemoveClient(s protocols.ClientState) {
+	x.forget(func(surface FocusedSurface) bool {
+		return surface.Client == s
+	})
+}
+
+func (x *XWindowState) forget(matches func(surface FocusedSurface) bool) {
+	x.Access.Lock()
+	defer x.Access.Unlock()
+	for serial, surface := range x.Unpaired {
+		if matches(surface) {
+			delete(x.Unpaired, serial)
+		}
+	}
+	for _, window := range x.Windows {
+		if window.Surface != nil && matches(*window.Surface) {
+			window.Surface = nil
+		}
+	}
+}
+
+func queuePosition(surface FocusedSurface, xPos, yPos int32) {
+	surface.Client.QueueTask(func() {
+		wl_surface := GetWlSurfaceObject(surface.Client, surface.SurfaceID)
+		if wl_surface == nil {
+			return
+		}
+		wl_surface.Position.X = xPos
+		wl_surface.Position.Y = yPos
+	})
+}
@@ -73,6 +73,15 @@ type Entry struct {
 type EventOrRequestAttr struct {
 	Name  string  `xml:"name,attr"`
 	Since *string `xml:"since,attr,omitempty"`
+	Type  string  `xml:"type,attr,omitempty"`
+}
+
+/**
+ * A destructor request or event ends the object's life,
+ * after it the object is removed and its id freed.
+ */
+func (e EventOrRequestAttr) IsDestructor() bool {
+	return e.Type == "destructor"
 }
 
 type EventOrRequest struct {
@@ -169,6 +178,7 @@ type argXML struct {
 type eventOrRequestXML struct {
 	Name        string       `xml:"name,attr"`
 	Since       *string      `xml:"since,attr,omitempty"`
+	Type        string       `xml:"type,attr,omitempty"`
 	Description *Description `xml:"description"`
 	Args        []argXML     `xml:"arg"`
 }
@@ -193,6 +203,7 @@ func convertEventOrRequestXML(x eventOrRequestXML) (EventOrRequest, error) {
 		EventOrRequestAttr: EventOrRequestAttr{
 			Name:  x.Name,
 			Since: x.Since,
+			Type:  x.Type,
 		},
 		Description: x.Description,
 		Args:        make([]Arg, 0, len(x.Args)),
@@ -338,6 +349,9 @@ func generateGoType(interfaceName string, a Arg, event bool) string {
 		return fmt.Sprintf("%s %s", name, enumName(interfaceName, *v.Enum))
 
 	case *ArgString:
+		if v.AllowNull != nil && *v.AllowNull {
+			return fmt.Sprintf("%s *string", name)
+		}
 		return fmt.Sprintf("%s string", name)
 
 	case *ArgInt:
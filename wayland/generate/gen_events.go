@@ -19,9 +19,14 @@ func genEvents(i Interface) string {
 
 		out.WriteString("\n")
 		out.WriteString(fmt.Sprintf("func %s_%s(", i.Name, ev.Name))
-		out.WriteString("s Sender, ")
-		if ev.Since != nil && *ev.Since != "" {
-			out.WriteString("boundVersion uint32, ")
+		if ev.IsDestructor() || len(eventNewIDNames(ev)) > 0 {
+			/**
+			 * It needs to destroy the object after sending,
+			 * or give the objects it makes a version.
+			 */
+			out.WriteString("s ClientState, ")
+		} else {
+			out.WriteString("s Sender, ")
 		}
 		out.WriteString(fmt.Sprintf("eventObjectID ObjectID[%s]", i.Name))
 		if len(argSig) > 0 {
@@ -30,9 +35,9 @@ func genEvents(i Interface) string {
 		}
 		out.WriteString(") {\n")
 
-		if ev.Since != nil && *ev.Since != "" {
-			out.WriteString(fmt.Sprintf("    if boundVersion < %s {\n", *ev.Since))
-			out.WriteString("        // Event not available in this version; skip\n")
+		if ev.Since != nil && *ev.Since != "" && *ev.Since != "1" {
+			out.WriteString(fmt.Sprintf("    if s.ObjectVersion(AnyObjectID(eventObjectID)) < %s {\n", *ev.Since))
+			out.WriteString("        // The client bound a version without this event\n")
 			out.WriteString("        return\n")
 			out.WriteString("    }\n")
 		}
@@ -88,6 +93,22 @@ func genEvents(i Interface) string {
 			case *ArgFd:
 				out.WriteString(fmt.Sprintf("    fileDescriptor = &%s\n", name))
 			case *ArgString:
+				if v.AllowNull != nil && *v.AllowNull {
+					out.WriteString(fmt.Sprintf(
+						"    if %s == nil {\n"+
+							"        putUint32(0)\n"+
+							"    } else {\n"+
+							"        b := []byte(*%s)\n"+
+							"        total := len(b) + 1 // include null terminator\n"+
+							"        putUint32(uint32(total))\n"+
+							"        data = append(data, b...)\n"+
+							"        data = append(data, 0)\n"+
+							"        if pad := (4 - (total %% 4)) %% 4; pad != 0 {\n"+
+							"            data = append(data, make([]byte, pad)...)\n"+
+							"        }\n"+
+							"    }\n", name, name))
+					break
+				}
 				out.WriteString(fmt.Sprintf(
 					"    {\n"+
 						"        b := []byte(%s)\n"+
@@ -119,10 +140,28 @@ func genEvents(i Interface) string {
 		out.WriteString("        Data:           data,\n")
 		out.WriteString("        FileDescriptor: fileDescriptor,\n")
 		out.WriteString("    }\n")
+		for _, name := range eventNewIDNames(ev) {
+			fmt.Fprintf(&out, "    s.SetObjectVersion(AnyObjectID(%s), s.ObjectVersion(AnyObjectID(eventObjectID)))\n", name)
+		}
 		out.WriteString("    s.Send(obj)\n")
+		if ev.IsDestructor() {
+			out.WriteString("    s.DestroyObject(AnyObjectID(eventObjectID))\n")
+		}
 
 		out.WriteString("}\n")
 	}
 
 	return out.String()
 }
+
+/**
+ * The objects the event makes, which get
+ * the version of the object it is sent to
+ */
+func eventNewIDNames(ev EventOrRequest) []string {
+	var names []string
+	for _, a := range ev.Args {
+		names = append(names, newIDNames(a)...)
+	}
+	return names
+}
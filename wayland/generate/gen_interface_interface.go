@@ -25,7 +25,7 @@ func genInterfaceInterface(iface Interface) string {
 		methodName := fmt.Sprintf("%s_%s", iface.Name, req.Name)
 		signature := fmt.Sprintf("%s(%s)", methodName, strings.Join(params, ", "))
 
-		if req.Name == "destroy" || req.Name == "release" {
+		if req.IsDestructor() {
 			signature += " bool"
 		}
 
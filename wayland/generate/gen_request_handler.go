@@ -46,7 +46,11 @@ func genRequestHandler(i Interface) string {
 			debugArgs = "\")\""
 		}
 
-		isAutoRemove := req.Name == "release" || req.Name == "destroy"
+		/**
+		 * The delegate returns false if it
+		 * destroys the object itself, later.
+		 */
+		isAutoRemove := req.IsDestructor()
 
 		fmt.Fprintf(&out, "case %d: {\n\n", idx)
 
@@ -55,6 +59,21 @@ func genRequestHandler(i Interface) string {
 			out.WriteString("\n")
 		}
 
+		for _, a := range req.Args {
+			for _, nm := range newIDNames(a) {
+				fmt.Fprintf(&out, "if !s.ValidNewID(AnyObjectID(%s)) {\n  break\n}\n", nm)
+				/**
+				 * Objects get the version the client asked
+				 * to bind, or the version of their factory.
+				 */
+				if v, ok := a.(*ArgNewID); ok && v.Interface == nil {
+					fmt.Fprintf(&out, "s.SetObjectVersion(AnyObjectID(%s), %sVersion)\n", nm, sanitizedArgName(a))
+				} else {
+					fmt.Fprintf(&out, "s.SetObjectVersion(AnyObjectID(%s), s.ObjectVersion(message.ObjectID))\n", nm)
+				}
+			}
+		}
+
 		out.WriteString("if DebugRequests {\n")
 		fmt.Fprintf(&out, "  fmt.Print(\"%s@\", message.ObjectID, \".%s(\")\n", i.Name, req.Name)
 		if len(debugPieces) > 0 {
@@ -71,22 +90,19 @@ func genRequestHandler(i Interface) string {
 			fmt.Fprintf(&out, "%s\n", call)
 		}
 
-		if req.Name == "release" {
+		if isAutoRemove {
 			out.WriteString("if autoRemove {\n")
-			out.WriteString("  s.RemoveObject(message.ObjectID)\n")
+			out.WriteString("  s.DestroyObject(message.ObjectID)\n")
 
-			switch i.Name {
-			case "WlShm", "WlSeat", "WlOutput", "WlKeyboard", "WlPointer", "WlTouch", "WlDataDevice", "ZwpXwaylandKeyboardGrabManagerV1":
-				fmt.Fprintf(&out, "  s.RemoveGlobal%sBind(ObjectID[%s](message.ObjectID))\n", i.Name, i.Name)
+			if req.Name == "release" {
+				switch i.Name {
+				case "WlShm", "WlSeat", "WlOutput", "WlKeyboard", "WlPointer", "WlTouch", "WlDataDevice", "ZwpXwaylandKeyboardGrabManagerV1":
+					fmt.Fprintf(&out, "  s.RemoveGlobal%sBind(ObjectID[%s](message.ObjectID))\n", i.Name, i.Name)
+				}
 			}
 
 			out.WriteString("}\n")
 		}
-		if req.Name == "destroy" {
-			out.WriteString("if autoRemove {\n")
-			out.WriteString("  s.RemoveObject(message.ObjectID)\n")
-			out.WriteString("}\n")
-		}
 
 		out.WriteString("break\n")
 		out.WriteString("}\n\n")
@@ -95,12 +111,27 @@ func genRequestHandler(i Interface) string {
 	return out.String()
 }
 
+/**
+ * The names of the variables holding the new ids the
+ * client picked for the objects a request creates
+ */
+func newIDNames(a Arg) []string {
+	v, ok := a.(*ArgNewID)
+	if !ok {
+		return nil
+	}
+	if v.Interface == nil {
+		return []string{sanitizedArgName(a) + "ID"}
+	}
+	return []string{sanitizedArgName(a)}
+}
+
 func genArgParseCode(a Arg, interfaceName string) string {
 	name := sanitizedArgName(a)
 
 	switch v := a.(type) {
 	case *ArgFixed:
-		return fmt.Sprintf(`%sRaw := uint32(message.Data[_data_in_offset__+0]) | uint32(message.Data[_data_in_offset__+1])<<8 |
+		return genBoundsCheck("4") + fmt.Sprintf(`%sRaw := uint32(message.Data[_data_in_offset__+0]) | uint32(message.Data[_data_in_offset__+1])<<8 |
   uint32(message.Data[_data_in_offset__+2])<<16 | uint32(message.Data[_data_in_offset__+3])<<24
 %s := float64(int32(%sRaw)) / 256.0
 _data_in_offset__ += 4
@@ -112,7 +143,7 @@ _data_in_offset__ += 4
 				genArgParseCode(&ArgUint{ArgCommon: ArgCommon{ArgName: name + "Version"}}, interfaceName) +
 				genArgParseCode(&ArgObject{ArgCommon: ArgCommon{ArgName: name + "ID"}}, interfaceName)
 		}
-		return fmt.Sprintf(`%sVal := uint32(message.Data[_data_in_offset__+0]) | uint32(message.Data[_data_in_offset__+1])<<8 |
+		return genBoundsCheck("4") + fmt.Sprintf(`%sVal := uint32(message.Data[_data_in_offset__+0]) | uint32(message.Data[_data_in_offset__+1])<<8 |
   uint32(message.Data[_data_in_offset__+2])<<16 | uint32(message.Data[_data_in_offset__+3])<<24
 %s := ObjectID[%s](%sVal)
 _data_in_offset__ += 4
@@ -120,18 +151,18 @@ _data_in_offset__ += 4
 
 	case *ArgUint:
 		if v.Enum != nil {
-			return fmt.Sprintf(`%s := %s(uint32(message.Data[_data_in_offset__+0]) | uint32(message.Data[_data_in_offset__+1])<<8 |
+			return genBoundsCheck("4") + fmt.Sprintf(`%s := %s(uint32(message.Data[_data_in_offset__+0]) | uint32(message.Data[_data_in_offset__+1])<<8 |
   uint32(message.Data[_data_in_offset__+2])<<16 | uint32(message.Data[_data_in_offset__+3])<<24)
 _data_in_offset__ += 4
 `, name, enumName(interfaceName, *v.Enum))
 		}
-		return fmt.Sprintf(`%s := uint32(message.Data[_data_in_offset__+0]) | uint32(message.Data[_data_in_offset__+1])<<8 |
+		return genBoundsCheck("4") + fmt.Sprintf(`%s := uint32(message.Data[_data_in_offset__+0]) | uint32(message.Data[_data_in_offset__+1])<<8 |
   uint32(message.Data[_data_in_offset__+2])<<16 | uint32(message.Data[_data_in_offset__+3])<<24
 _data_in_offset__ += 4
 `, name)
 
 	case *ArgInt:
-		return fmt.Sprintf(`%s := int32(uint32(message.Data[_data_in_offset__+0]) | uint32(message.Data[_data_in_offset__+1])<<8 |
+		return genBoundsCheck("4") + fmt.Sprintf(`%s := int32(uint32(message.Data[_data_in_offset__+0]) | uint32(message.Data[_data_in_offset__+1])<<8 |
   uint32(message.Data[_data_in_offset__+2])<<16 | uint32(message.Data[_data_in_offset__+3])<<24)
 _data_in_offset__ += 4
 `, name)
@@ -139,7 +170,7 @@ _data_in_offset__ += 4
 	case *ArgObject:
 		if v.Interface != nil {
 			if v.AllowNull != nil && *v.AllowNull {
-				return fmt.Sprintf(`%sTmp := uint32(message.Data[_data_in_offset__+0]) | uint32(message.Data[_data_in_offset__+1])<<8 |
+				return genBoundsCheck("4") + fmt.Sprintf(`%sTmp := uint32(message.Data[_data_in_offset__+0]) | uint32(message.Data[_data_in_offset__+1])<<8 |
   uint32(message.Data[_data_in_offset__+2])<<16 | uint32(message.Data[_data_in_offset__+3])<<24
 _data_in_offset__ += 4
 var %s *ObjectID[%s]
@@ -149,20 +180,35 @@ if %sTmp != 0 {
 }
 `, name, name, *v.Interface, name, *v.Interface, name, name)
 			}
-			return fmt.Sprintf(`%s := ObjectID[%s](uint32(message.Data[_data_in_offset__+0]) | uint32(message.Data[_data_in_offset__+1])<<8 |
+			return genBoundsCheck("4") + fmt.Sprintf(`%s := ObjectID[%s](uint32(message.Data[_data_in_offset__+0]) | uint32(message.Data[_data_in_offset__+1])<<8 |
   uint32(message.Data[_data_in_offset__+2])<<16 | uint32(message.Data[_data_in_offset__+3])<<24)
 _data_in_offset__ += 4
 `, name, *v.Interface)
 		}
-		return fmt.Sprintf(`%s := AnyObjectID(uint32(message.Data[_data_in_offset__+0]) | uint32(message.Data[_data_in_offset__+1])<<8 |
+		return genBoundsCheck("4") + fmt.Sprintf(`%s := AnyObjectID(uint32(message.Data[_data_in_offset__+0]) | uint32(message.Data[_data_in_offset__+1])<<8 |
   uint32(message.Data[_data_in_offset__+2])<<16 | uint32(message.Data[_data_in_offset__+3])<<24)
 _data_in_offset__ += 4
 `, name)
 
 	case *ArgString:
-		return fmt.Sprintf(`%sLen := int(uint32(message.Data[_data_in_offset__+0]) | uint32(message.Data[_data_in_offset__+1])<<8 |
-  uint32(message.Data[_data_in_offset__+2])<<16 | uint32(message.Data[_data_in_offset__+3])<<24)
-_data_in_offset__ += 4
+		if v.AllowNull != nil && *v.AllowNull {
+			return genLengthParseCode(name) + fmt.Sprintf(`var %s *string
+if %sLen > 0 {
+  tmp := string(message.Data[_data_in_offset__ : _data_in_offset__+%sLen-1]) // NUL-terminated
+  %s = &tmp
+}
+// 4-byte alignment
+if %sLen%%4 != 0 {
+  _data_in_offset__ += %sLen + (4 - (%sLen %% 4))
+} else {
+  _data_in_offset__ += %sLen
+}
+`, name, name, name, name, name, name, name, name)
+		}
+		return genLengthParseCode(name) + fmt.Sprintf(`if %sLen == 0 {
+  s.SendError(message.ObjectID, uint32(WlDisplayError_enum_invalid_method), "null string for a non-nullable argument")
+  break
+}
 %s := string(message.Data[_data_in_offset__ : _data_in_offset__+%sLen-1]) // NUL-terminated
 // 4-byte alignment
 if %sLen%%4 != 0 {
@@ -173,16 +219,13 @@ if %sLen%%4 != 0 {
 `, name, name, name, name, name, name, name)
 
 	case *ArgArray:
-		return fmt.Sprintf(`%sLen := int(uint32(message.Data[_data_in_offset__+0]) | uint32(message.Data[_data_in_offset__+1])<<8 |
-  uint32(message.Data[_data_in_offset__+2])<<16 | uint32(message.Data[_data_in_offset__+3])<<24)
-_data_in_offset__ += 4
-%s := message.Data[_data_in_offset__ : _data_in_offset__+%sLen]
+		return genLengthParseCode(name) + fmt.Sprintf(`%s := message.Data[_data_in_offset__ : _data_in_offset__+%sLen]
 if %sLen%%4 != 0 {
   _data_in_offset__ += %sLen + (4 - (%sLen %% 4))
 } else {
   _data_in_offset__ += %sLen
 }
-`, name, name, name, name, name, name, name)
+`, name, name, name, name, name, name)
 
 	case *ArgFd:
 		return fmt.Sprintf(`%s := s.ClaimFileDescriptor()
@@ -192,3 +235,26 @@ if %sLen%%4 != 0 {
 		panic(fmt.Errorf("unknown arg kind: %T", a))
 	}
 }
+
+/**
+ * Read the length of a string or array, and
+ * check that it (padded to 4 bytes) is all there
+ */
+func genLengthParseCode(name string) string {
+	return genBoundsCheck("4") + fmt.Sprintf(`%sLen := int(uint32(message.Data[_data_in_offset__+0]) | uint32(message.Data[_data_in_offset__+1])<<8 |
+  uint32(message.Data[_data_in_offset__+2])<<16 | uint32(message.Data[_data_in_offset__+3])<<24)
+_data_in_offset__ += 4
+`, name) + genBoundsCheck(fmt.Sprintf("((%sLen+3)&^3)", name))
+}
+
+/**
+ * The message ends before the next n bytes
+ * of arguments, so the request is not handled.
+ */
+func genBoundsCheck(n string) string {
+	return fmt.Sprintf(`if _data_in_offset__+%s > len(message.Data) {
+  s.SendError(message.ObjectID, uint32(WlDisplayError_enum_invalid_method), "request too short")
+  break
+}
+`, n)
+}
@@ -4,31 +4,51 @@ type GlobalID AnyObjectID
 type Version uint32
 
 const (
-	GlobalID_WlDisplay                        GlobalID = 1
-	GlobalID_WlCompositor                     GlobalID = 0xff00000
-	GlobalID_WlSubcompositor                  GlobalID = 0xff00001
-	GlobalID_WlOutput                         GlobalID = 0xff00002
-	GlobalID_WlSeat                           GlobalID = 0xff00003
-	GlobalID_WlShm                            GlobalID = 0xff00004
-	GlobalID_XdgWmBase                        GlobalID = 0xff00005
-	GlobalID_WlDataDeviceManager              GlobalID = 0xff00006
-	GlobalID_WlKeyboard                       GlobalID = 0xff00007
-	GlobalID_WlPointer                        GlobalID = 0xff00008
-	GlobalID_ZwpXwaylandKeyboardGrabManagerV1 GlobalID = 0xff00009
-	GlobalID_XwaylandShellV1                  GlobalID = 0xff00011
-	GlobalID_WlDataDevice                     GlobalID = 0xff00012
-	GlobalID_WlTouch                          GlobalID = 0xff00013
-	GlobalID_ZxdgDecorationManagerV1          GlobalID = 0xff00014
+	GlobalID_WlDisplay                          GlobalID = 1
+	GlobalID_WlCompositor                       GlobalID = 0xff00000
+	GlobalID_WlSubcompositor                    GlobalID = 0xff00001
+	GlobalID_WlOutput                           GlobalID = 0xff00002
+	GlobalID_WlSeat                             GlobalID = 0xff00003
+	GlobalID_WlShm                              GlobalID = 0xff00004
+	GlobalID_XdgWmBase                          GlobalID = 0xff00005
+	GlobalID_WlDataDeviceManager                GlobalID = 0xff00006
+	GlobalID_WlKeyboard                         GlobalID = 0xff00007
+	GlobalID_WlPointer                          GlobalID = 0xff00008
+	GlobalID_ZwpXwaylandKeyboardGrabManagerV1   GlobalID = 0xff00009
+	GlobalID_XwaylandShellV1                    GlobalID = 0xff00011
+	GlobalID_WlDataDevice                       GlobalID = 0xff00012
+	GlobalID_WlTouch                            GlobalID = 0xff00013
+	GlobalID_ZxdgDecorationManagerV1            GlobalID = 0xff00014
+	GlobalID_ZwpPrimarySelectionDeviceManagerV1 GlobalID = 0xff00015
+	GlobalID_WpSecurityContextManagerV1         GlobalID = 0xff00016
+)
+
+/**
+ * Which clients a global is advertised to
+ */
+type GlobalAudience int
+
+const (
+	GlobalAudience_Everyone GlobalAudience = iota
+	/**
+	 * Only the Xwayland we started ourselves
+	 */
+	GlobalAudience_Xwayland
+	/**
+	 * Hidden from clients in a sandbox (wp_security_context_v1)
+	 */
+	GlobalAudience_Unsandboxed
 )
 
 type AdvertisedGlobalObjectName struct {
-	Name    string
-	Id      GlobalID
-	Version uint32
+	Name     string
+	Id       GlobalID
+	Version  uint32
+	Audience GlobalAudience
 }
 
 var AdvertisedGlobalObjectNames = []AdvertisedGlobalObjectName{
-	{"wl_compositor", GlobalID_WlCompositor, 6},
+	{"wl_compositor", GlobalID_WlCompositor, 6, GlobalAudience_Everyone},
 	/**
 	 * Turning off the wl_subcompositor will turn off
 	 * decorations. Any other side effects??? Looks like
@@ -38,19 +58,53 @@ var AdvertisedGlobalObjectNames = []AdvertisedGlobalObjectName{
 	 * some programs will crash if wl_subcompositor is not
 	 * advertised.
 	 */
-	{"wl_subcompositor", GlobalID_WlSubcompositor, 1},
-	{"wl_output", GlobalID_WlOutput, 5},
-
-	{"wl_seat", GlobalID_WlSeat, 10},
-	{"wl_shm", GlobalID_WlShm, 2},
-	{"xdg_wm_base", GlobalID_XdgWmBase, 6},
-	{"wl_data_device_manager", GlobalID_WlDataDeviceManager, 3},
-	{"zxdg_decoration_manager_v1", GlobalID_ZxdgDecorationManagerV1, 1},
+	{"wl_subcompositor", GlobalID_WlSubcompositor, 1, GlobalAudience_Everyone},
+	{"wl_output", GlobalID_WlOutput, 5, GlobalAudience_Everyone},
+
+	{"wl_seat", GlobalID_WlSeat, 10, GlobalAudience_Everyone},
+	{"wl_shm", GlobalID_WlShm, 2, GlobalAudience_Everyone},
+	{"xdg_wm_base", GlobalID_XdgWmBase, 6, GlobalAudience_Everyone},
+	{"wl_data_device_manager", GlobalID_WlDataDeviceManager, 3, GlobalAudience_Everyone},
+	{"zxdg_decoration_manager_v1", GlobalID_ZxdgDecorationManagerV1, 1, GlobalAudience_Everyone},
+	{"zwp_primary_selection_device_manager_v1", GlobalID_ZwpPrimarySelectionDeviceManagerV1, 1, GlobalAudience_Everyone},
+	{"zwp_xwayland_keyboard_grab_manager_v1", GlobalID_ZwpXwaylandKeyboardGrabManagerV1, 1, GlobalAudience_Xwayland},
+	{"xwayland_shell_v1", GlobalID_XwaylandShellV1, 1, GlobalAudience_Xwayland},
 	/**
-	 * @TODO only advertise these to Xwayland clients
+	 * Sandboxes can't make sandboxes, a nested
+	 * one could have fewer restrictions.
 	 */
-	{"zwp_xwayland_keyboard_grab_manager_v1", GlobalID_ZwpXwaylandKeyboardGrabManagerV1, 1},
-	{"xwayland_shell_v1", GlobalID_XwaylandShellV1, 1},
+	{"wp_security_context_manager_v1", GlobalID_WpSecurityContextManagerV1, 1, GlobalAudience_Unsandboxed},
+}
+
+/**
+ * Can the client see (and bind) the global
+ */
+func (g AdvertisedGlobalObjectName) VisibleTo(cs ClientState) bool {
+	context := cs.GetSecurityContext()
+	switch g.Audience {
+	case GlobalAudience_Xwayland:
+		if !cs.IsXwayland() {
+			return false
+		}
+	case GlobalAudience_Unsandboxed:
+		if context != nil {
+			return false
+		}
+	}
+	return context == nil || !context.HiddenGlobals[g.Name]
+}
+
+/**
+ * false for globals hidden from the client,
+ * and for ids that aren't advertised at all.
+ */
+func GlobalVisibleTo(cs ClientState, id GlobalID) bool {
+	for _, global := range AdvertisedGlobalObjectNames {
+		if global.Id == id {
+			return global.VisibleTo(cs)
+		}
+	}
+	return false
 }
 
 func GetGlobalWlDisplayBinds(cs ClientState) map[ObjectID[WlDisplay]]Version {
@@ -202,3 +256,13 @@ func GetGlobalZxdgDecorationManagerV1Binds(cs ClientState) map[ObjectID[ZxdgDeco
 	m := v.(map[ObjectID[ZxdgDecorationManagerV1]]Version)
 	return m
 }
+
+func GetGlobalZwpPrimarySelectionDeviceManagerV1Binds(cs ClientState) map[ObjectID[ZwpPrimarySelectionDeviceManagerV1]]Version {
+
+	v := cs.GetGlobalBinds(GlobalID(GlobalID_ZwpPrimarySelectionDeviceManagerV1))
+	if v == nil {
+		return nil
+	}
+	m := v.(map[ObjectID[ZwpPrimarySelectionDeviceManagerV1]]Version)
+	return m
+}
@@ -25,6 +25,23 @@ type OnRequestable interface {
 // Don't use these functions directly; use the ones in wayland/types.go
 type ClientState interface {
 	RemoveObject(AnyObjectID)
+	/**
+	 * Remove the object and send wl_display.delete_id,
+	 * for destructor requests and events.
+	 */
+	DestroyObject(AnyObjectID)
+	/**
+	 * Can the client create an object with this id, if not
+	 * a protocol error was sent.
+	 */
+	ValidNewID(AnyObjectID) bool
+	/**
+	 * The version the object was bound with, or
+	 * for objects made by another, that one's version.
+	 * Events newer than it are not sent.
+	 */
+	ObjectVersion(AnyObjectID) uint32
+	SetObjectVersion(AnyObjectID, uint32)
 	// RemoveGlobalBind(GlobalID, AnyObjectID)
 	AddObject(AnyObjectID, any)
 	SetCompositorVersion(uint32)
@@ -47,6 +64,29 @@ type ClientState interface {
 	FindDescendantSurface(ObjectID[WlSurface], ObjectID[WlSurface]) bool
 
 	GetGlobalBinds(GlobalID) any
+
+	/**
+	 * Allocate an object id in the server range
+	 * (0xff000000 and up) for objects the server creates,
+	 * like wl_data_offer.
+	 */
+	NewServerObjectID() AnyObjectID
+	/**
+	 * Run task on the client's own goroutine while holding
+	 * its lock. Use this when one client's request needs to
+	 * touch another client's objects.
+	 */
+	QueueTask(func())
+	/**
+	 * Is this the Xwayland we started, it alone
+	 * sees the Xwayland specific globals.
+	 */
+	IsXwayland() bool
+	/**
+	 * nil unless the client connected through
+	 * a wp_security_context_v1 socket.
+	 */
+	GetSecurityContext() *SecurityContext
 	// AddGlobalBind(GlobalID, AnyObjectID, Version)
 
 	AddGlobalWlShmBind(ObjectID[WlShm], Version)
@@ -56,6 +96,7 @@ type ClientState interface {
 	AddGlobalWlPointerBind(ObjectID[WlPointer], Version)
 	AddGlobalWlTouchBind(ObjectID[WlTouch], Version)
 	AddGlobalWlDataDeviceBind(ObjectID[WlDataDevice], Version)
+	AddGlobalWlDataDeviceManagerBind(ObjectID[WlDataDeviceManager], Version)
 	AddGlobalZwpXwaylandKeyboardGrabManagerV1Bind(ObjectID[ZwpXwaylandKeyboardGrabManagerV1], Version)
 
 	RemoveGlobalWlShmBind(ObjectID[WlShm])
@@ -65,6 +106,7 @@ type ClientState interface {
 	RemoveGlobalWlPointerBind(ObjectID[WlPointer])
 	RemoveGlobalWlTouchBind(ObjectID[WlTouch])
 	RemoveGlobalWlDataDeviceBind(ObjectID[WlDataDevice])
+	RemoveGlobalWlDataDeviceManagerBind(ObjectID[WlDataDeviceManager])
 	RemoveGlobalZwpXwaylandKeyboardGrabManagerV1Bind(ObjectID[ZwpXwaylandKeyboardGrabManagerV1])
 }
 
@@ -73,6 +115,12 @@ type OutgoingEvent struct {
 	Opcode         uint16
 	Data           []byte
 	FileDescriptor *FileDescriptor
+	/**
+	 * Close FileDescriptor on our side once it has been
+	 * handed to the client. Used for pipes the compositor
+	 * creates and passes along.
+	 */
+	CloseFileDescriptorAfterSend bool
 }
 
 type FileDescriptorClaimClientState interface {
@@ -81,12 +129,25 @@ type FileDescriptorClaimClientState interface {
 }
 type FileDescriptor int
 
+/**
+ * What a sandbox engine told us, with wp_security_context_v1,
+ * about the clients connecting through the socket it made.
+ */
+type SecurityContext struct {
+	SandboxEngine string
+	AppID         string
+	InstanceID    string
+	/**
+	 * Names of the globals hidden from these clients
+	 */
+	HiddenGlobals map[string]bool
+}
+
 type Sender interface {
 	Send(OutgoingEvent)
+	ObjectVersion(AnyObjectID) uint32
 }
 
-type DecodeStateType int
-
 type Message struct {
 	ObjectID AnyObjectID
 	Opcode   uint16
@@ -94,13 +155,4 @@ type Message struct {
 	Data     []byte
 }
 
-type DecodeState struct {
-	Phase    DecodeStateType
-	I        uint // bit offset within current field (0,8,16,24)
-	ObjectID AnyObjectID
-	Opcode   uint16
-	Size     uint16
-	Data     []byte
-}
-
 type Fixed = float64
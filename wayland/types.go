@@ -1,6 +1,8 @@
 package wayland
 
 import (
+	"encoding/binary"
+
 	"github.com/mmulet/term.everything/wayland/protocols"
 )
 
@@ -50,10 +52,14 @@ type Size struct {
 	Height uint32
 }
 
+/**
+ * Enums in a wl_array, like xdg_toplevel states,
+ * are 32 bits each.
+ */
 func ToBytes[T ~uint8 | ~uint32](a []T) []byte {
-	b := make([]byte, len(a))
-	for i, v := range a {
-		b[i] = byte(v)
+	b := make([]byte, 0, len(a)*4)
+	for _, v := range a {
+		b = binary.LittleEndian.AppendUint32(b, uint32(v))
 	}
 	return b
 }
@@ -15,6 +15,13 @@ func (c *WlCompositor) WlCompositor_create_surface(
 
 	AddObject(s, id, surface)
 
+	/**
+	 * There is only the one virtual monitor, at scale 1
+	 * and not rotated, so this never changes.
+	 */
+	protocols.WlSurface_preferred_buffer_scale(s, id, 1)
+	protocols.WlSurface_preferred_buffer_transform(s, id, protocols.WlOutputTransform_enum_normal)
+
 	// // s.bound_compositor_info?.surfaces.set(id, new Surface_Info(surface, 1));
 	// // console.log("create surface", id);
 	// /**
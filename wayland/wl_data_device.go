@@ -6,32 +6,67 @@ import (
 
 type wl_data_device struct {
 	Seat protocols.ObjectID[protocols.WlSeat]
+	ID   protocols.ObjectID[protocols.WlDataDevice]
 }
 
 func (w *wl_data_device) WlDataDevice_start_drag(
-	_s protocols.ClientState,
-	_object_id protocols.ObjectID[protocols.WlDataDevice],
-	_source *protocols.ObjectID[protocols.WlDataSource],
-	_origin protocols.ObjectID[protocols.WlSurface],
-	_icon *protocols.ObjectID[protocols.WlSurface],
+	s protocols.ClientState,
+	object_id protocols.ObjectID[protocols.WlDataDevice],
+	source *protocols.ObjectID[protocols.WlDataSource],
+	origin protocols.ObjectID[protocols.WlSurface],
+	icon *protocols.ObjectID[protocols.WlSurface],
 	_serial uint32,
 ) {
-	/** @TODO: Implement wl_data_device_start_drag */
+	/**
+	 * @TODO check the serial against the
+	 * implicit grab of a pointer button press
+	 */
+	if icon != nil {
+		iconSurface := GetWlSurfaceObject(s, *icon)
+		if iconSurface == nil {
+			return
+		}
+		if _, isDragIcon := iconSurface.Role.(*SurfaceRoleDragIcon); iconSurface.Role != nil && !isDragIcon {
+			SendError(s, object_id, protocols.WlDataDeviceError_enum_role, "icon surface already has a role")
+			return
+		}
+		iconSurface.Role = &SurfaceRoleDragIcon{}
+	}
+
+	var dragSource DragSource
+	if source != nil {
+		dataSource := GetWlDataSourceObject(s, *source)
+		if dataSource == nil {
+			return
+		}
+		dragSource = dataSource
+	}
+	DragAndDrop.Start(s, dragSource, origin, icon)
 }
 
 func (w *wl_data_device) WlDataDevice_set_selection(
-	_s protocols.ClientState,
+	s protocols.ClientState,
 	_object_id protocols.ObjectID[protocols.WlDataDevice],
-	_source *protocols.ObjectID[protocols.WlDataSource],
+	source *protocols.ObjectID[protocols.WlDataSource],
 	_serial uint32,
 ) {
-	/** @TODO: Implement wl_data_device_set_selection */
+	if source == nil {
+		Clipboard.SetSelection(nil)
+		return
+	}
+	dataSource := GetWlDataSourceObject(s, *source)
+	if dataSource == nil {
+		return
+	}
+	Clipboard.SetSelection(dataSource)
 }
 
 func (w *wl_data_device) WlDataDevice_release(
-	_s protocols.ClientState,
-	_object_id protocols.ObjectID[protocols.WlDataDevice],
+	s protocols.ClientState,
+	object_id protocols.ObjectID[protocols.WlDataDevice],
 ) bool {
+	Clipboard.RemoveDevice(s, protocols.AnyObjectID(object_id))
+	DragAndDrop.RemoveDevice(s, object_id)
 	return true
 }
 
@@ -45,6 +80,38 @@ func (w *wl_data_device) WlDataDevice_on_bind(
 	/** @TODO: Implement wl_data_device_on_bind */
 }
 
-func MakeWlDataDevice(seat protocols.ObjectID[protocols.WlSeat]) *wl_data_device {
-	return &wl_data_device{Seat: seat}
+func (w *wl_data_device) OnBind(
+	_s protocols.ClientState,
+	_ protocols.AnyObjectID,
+	_ string,
+	_ protocols.AnyObjectID,
+	_version uint32,
+) {
+}
+
+/**
+ * Create a new wl_data_offer for source and
+ * announce it as the selection.
+ */
+func (w *wl_data_device) OfferSelection(s protocols.ClientState, source SelectionSource) {
+	if source == nil {
+		protocols.WlDataDevice_selection(s, w.ID, nil)
+		return
+	}
+	offerID := protocols.ObjectID[protocols.WlDataOffer](s.NewServerObjectID())
+	offer := MakeWlDataOffer(source)
+	offer.Delegate.(*WlDataOffer).Client = s
+	offer.Delegate.(*WlDataOffer).ID = offerID
+	s.AddObject(protocols.AnyObjectID(offerID), offer)
+	protocols.WlDataDevice_data_offer(s, w.ID, offerID)
+	for _, mimeType := range source.GetMimeTypes() {
+		protocols.WlDataOffer_offer(s, offerID, mimeType)
+	}
+	protocols.WlDataDevice_selection(s, w.ID, &offerID)
+}
+
+func MakeWlDataDevice(seat protocols.ObjectID[protocols.WlSeat], id protocols.ObjectID[protocols.WlDataDevice]) *protocols.WlDataDevice {
+	return &protocols.WlDataDevice{
+		Delegate: &wl_data_device{Seat: seat, ID: id},
+	}
 }
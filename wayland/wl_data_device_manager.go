@@ -7,12 +7,14 @@ import (
 type WlDataDeviceManagerImpl struct{}
 
 func (w *WlDataDeviceManagerImpl) WlDataDeviceManager_create_data_source(s protocols.ClientState, _object_id protocols.ObjectID[protocols.WlDataDeviceManager], id protocols.ObjectID[protocols.WlDataSource]) {
-	s.AddObject(protocols.AnyObjectID(id), MakeWlDataSource())
+	s.AddObject(protocols.AnyObjectID(id), MakeWlDataSource(s, id))
 }
 
 func (w *WlDataDeviceManagerImpl) WlDataDeviceManager_get_data_device(s protocols.ClientState, _object_id protocols.ObjectID[protocols.WlDataDeviceManager], id protocols.ObjectID[protocols.WlDataDevice], seat protocols.ObjectID[protocols.WlSeat]) {
-	s.AddObject(protocols.AnyObjectID(id), MakeWlDataDevice(seat))
-	/** @TODO: Implement wl_data_device_manager_get_data_device */
+	dataDevice := MakeWlDataDevice(seat, id)
+	s.AddObject(protocols.AnyObjectID(id), dataDevice)
+	Clipboard.AddDevice(s, protocols.AnyObjectID(id), dataDevice.Delegate.(*wl_data_device))
+	DragAndDrop.AddDevice(s, id, dataDevice.Delegate.(*wl_data_device))
 }
 
 func (w *WlDataDeviceManagerImpl) OnBind(
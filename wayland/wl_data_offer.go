@@ -0,0 +1,160 @@
+package wayland
+
+import (
+	"slices"
+	"syscall"
+
+	"github.com/mmulet/term.everything/wayland/protocols"
+)
+
+type WlDataOffer struct {
+	Source SelectionSource
+
+	Client protocols.ClientState
+	ID     protocols.ObjectID[protocols.WlDataOffer]
+
+	/**
+	 * Offers made by a drag, as opposed to the selection
+	 */
+	DragAndDrop bool
+
+	AcceptedMimeType *string
+	Actions          protocols.WlDataDeviceManagerDndAction_enum
+	PreferredAction  protocols.WlDataDeviceManagerDndAction_enum
+	/**
+	 * The action picked from what the source
+	 * and this offer both support.
+	 */
+	Action protocols.WlDataDeviceManagerDndAction_enum
+}
+
+func (w *WlDataOffer) WlDataOffer_accept(
+	_s protocols.ClientState,
+	_object_id protocols.ObjectID[protocols.WlDataOffer],
+	_serial uint32,
+	mime_type *string,
+) {
+	if !w.DragAndDrop {
+		return
+	}
+	w.AcceptedMimeType = mime_type
+	if source, ok := w.Source.(DragSource); ok {
+		source.Target(mime_type)
+	}
+	DragAndDrop.OfferChanged(w)
+}
+
+func (w *WlDataOffer) WlDataOffer_receive(
+	_s protocols.ClientState,
+	_object_id protocols.ObjectID[protocols.WlDataOffer],
+	mime_type string,
+	fd *protocols.FileDescriptor,
+) {
+	if fd == nil {
+		return
+	}
+	if w.Source == nil || !slices.Contains(w.Source.GetMimeTypes(), mime_type) {
+		syscall.Close(int(*fd))
+		return
+	}
+	w.Source.SendTo(mime_type, *fd)
+}
+
+func (w *WlDataOffer) WlDataOffer_destroy(
+	_s protocols.ClientState,
+	_object_id protocols.ObjectID[protocols.WlDataOffer],
+) bool {
+	return true
+}
+
+func (w *WlDataOffer) WlDataOffer_finish(
+	s protocols.ClientState,
+	object_id protocols.ObjectID[protocols.WlDataOffer],
+) {
+	if !w.DragAndDrop {
+		SendError(s, object_id, protocols.WlDataOfferError_enum_invalid_finish, "finish on a selection offer")
+		return
+	}
+	if w.AcceptedMimeType == nil || w.Action == protocols.WlDataDeviceManagerDndAction_enum_none {
+		SendError(s, object_id, protocols.WlDataOfferError_enum_invalid_finish, "finish without an accepted mime type and action")
+		return
+	}
+	if source, ok := w.Source.(DragSource); ok {
+		source.Finished()
+	}
+}
+
+func (w *WlDataOffer) WlDataOffer_set_actions(
+	s protocols.ClientState,
+	object_id protocols.ObjectID[protocols.WlDataOffer],
+	dnd_actions protocols.WlDataDeviceManagerDndAction_enum,
+	preferred_action protocols.WlDataDeviceManagerDndAction_enum,
+) {
+	if !w.DragAndDrop {
+		SendError(s, object_id, protocols.WlDataOfferError_enum_invalid_offer, "set_actions on a selection offer")
+		return
+	}
+	w.Actions = dnd_actions
+	w.PreferredAction = preferred_action
+	w.NegotiateAction()
+	DragAndDrop.OfferChanged(w)
+}
+
+/**
+ * Pick the action both sides support, preferring the one
+ * the destination asked for, then copy, move and ask.
+ */
+func (w *WlDataOffer) NegotiateAction() {
+	source, ok := w.Source.(DragSource)
+	if !ok {
+		return
+	}
+	available := source.GetActions() & w.Actions
+	action := protocols.WlDataDeviceManagerDndAction_enum_none
+	if available&w.PreferredAction != 0 {
+		action = w.PreferredAction
+	} else {
+		for _, candidate := range []protocols.WlDataDeviceManagerDndAction_enum{
+			protocols.WlDataDeviceManagerDndAction_enum_copy,
+			protocols.WlDataDeviceManagerDndAction_enum_move,
+			protocols.WlDataDeviceManagerDndAction_enum_ask,
+		} {
+			if available&candidate != 0 {
+				action = candidate
+				break
+			}
+		}
+	}
+	w.Action = action
+	protocols.WlDataOffer_action(w.Client, w.ID, action)
+	source.Action(action)
+}
+
+/**
+ * Before version 3 there are no actions,
+ * accepting a mime type is enough.
+ */
+func (w *WlDataOffer) WillAcceptDrop() bool {
+	if w.AcceptedMimeType == nil {
+		return false
+	}
+	if w.Client.ObjectVersion(protocols.AnyObjectID(w.ID)) < 3 {
+		return true
+	}
+	return w.Action != protocols.WlDataDeviceManagerDndAction_enum_none
+}
+
+func (w *WlDataOffer) OnBind(
+	_s protocols.ClientState,
+	_ protocols.AnyObjectID,
+	_ string,
+	_ protocols.AnyObjectID,
+	_version uint32,
+) {
+}
+
+func MakeWlDataOffer(source SelectionSource) *protocols.WlDataOffer {
+	return &protocols.WlDataOffer{
+		Delegate: &WlDataOffer{Source: source},
+	}
+}
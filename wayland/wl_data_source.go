@@ -1,12 +1,27 @@
 package wayland
 
 import (
+	"slices"
+	"sync"
+	"syscall"
+
 	"github.com/mmulet/term.everything/wayland/protocols"
 )
 
 type WlDataSource struct {
+	Client protocols.ClientState
+	ID     protocols.ObjectID[protocols.WlDataSource]
+
+	/**
+	 * Guards MimeTypes, Actions and Destroyed. The owner
+	 * changes them, the clients it is offered to read them.
+	 */
+	Access sync.Mutex
+
 	MimeTypes []string
 	Actions   protocols.WlDataDeviceManagerDndAction_enum
+
+	Destroyed bool
 }
 
 func (w *WlDataSource) WlDataSource_offer(
@@ -14,6 +29,8 @@ func (w *WlDataSource) WlDataSource_offer(
 	object_id protocols.ObjectID[protocols.WlDataSource],
 	mime_type string,
 ) {
+	w.Access.Lock()
+	defer w.Access.Unlock()
 	w.MimeTypes = append(w.MimeTypes, mime_type)
 }
 
@@ -21,6 +38,11 @@ func (w *WlDataSource) WlDataSource_destroy(
 	s protocols.ClientState,
 	object_id protocols.ObjectID[protocols.WlDataSource],
 ) bool {
+	w.Access.Lock()
+	w.Destroyed = true
+	w.Access.Unlock()
+	Clipboard.SourceDestroyed(w)
+	DragAndDrop.SourceDestroyed(w)
 	return true
 }
 
@@ -29,6 +51,8 @@ func (w *WlDataSource) WlDataSource_set_actions(
 	object_id protocols.ObjectID[protocols.WlDataSource],
 	dnd_actions protocols.WlDataDeviceManagerDndAction_enum,
 ) {
+	w.Access.Lock()
+	defer w.Access.Unlock()
 	w.Actions = dnd_actions
 }
 
@@ -42,8 +66,39 @@ func (w *WlDataSource) OnBind(
 	// TODO: Implement wl_data_source_on_bind
 }
 
-func MakeWlDataSource() *protocols.WlDataSource {
+func (w *WlDataSource) GetMimeTypes() []string {
+	w.Access.Lock()
+	defer w.Access.Unlock()
+	return slices.Clone(w.MimeTypes)
+}
+
+/**
+ * Ask the client to write its data into fd, we
+ * close our copy of fd once it has been sent.
+ */
+func (w *WlDataSource) SendTo(mime_type string, fd protocols.FileDescriptor) {
+	if w.IsDestroyed() || !slices.Contains(w.GetMimeTypes(), mime_type) {
+		syscall.Close(int(fd))
+		return
+	}
+	protocols.WlDataSource_send(closeFileDescriptorAfterSend{w.Client}, w.ID, mime_type, fd)
+}
+
+func (w *WlDataSource) Cancel() {
+	if w.IsDestroyed() {
+		return
+	}
+	protocols.WlDataSource_cancelled(w.Client, w.ID)
+}
+
+func (w *WlDataSource) Owner() protocols.ClientState {
+	return w.Client
+}
+
+func MakeWlDataSource(s protocols.ClientState, id protocols.ObjectID[protocols.WlDataSource]) *protocols.WlDataSource {
 	ws := &WlDataSource{
+		Client:    s,
+		ID:        id,
 		MimeTypes: []string{},
 		Actions:   protocols.WlDataDeviceManagerDndAction_enum_none,
 	}
@@ -51,3 +106,47 @@ func MakeWlDataSource() *protocols.WlDataSource {
 		Delegate: ws,
 	}
 }
+
+/**
+ * DragSource methods
+ */
+
+func (w *WlDataSource) GetActions() protocols.WlDataDeviceManagerDndAction_enum {
+	w.Access.Lock()
+	defer w.Access.Unlock()
+	return w.Actions
+}
+
+func (w *WlDataSource) IsDestroyed() bool {
+	w.Access.Lock()
+	defer w.Access.Unlock()
+	return w.Destroyed
+}
+
+func (w *WlDataSource) Target(mime_type *string) {
+	if w.IsDestroyed() {
+		return
+	}
+	protocols.WlDataSource_target(w.Client, w.ID, mime_type)
+}
+
+func (w *WlDataSource) Action(action protocols.WlDataDeviceManagerDndAction_enum) {
+	if w.IsDestroyed() {
+		return
+	}
+	protocols.WlDataSource_action(w.Client, w.ID, action)
+}
+
+func (w *WlDataSource) DropPerformed() {
+	if w.IsDestroyed() {
+		return
+	}
+	protocols.WlDataSource_dnd_drop_performed(w.Client, w.ID)
+}
+
+func (w *WlDataSource) Finished() {
+	if w.IsDestroyed() {
+		return
+	}
+	protocols.WlDataSource_dnd_finished(w.Client, w.ID)
+}
@@ -12,6 +12,9 @@ func (wd *wl_display) WlDisplay_get_registry(s protocols.ClientState, _object_id
 	registry_object := MakeWlRegistry()
 	AddObject(s, registry, registry_object)
 	for _, global := range protocols.AdvertisedGlobalObjectNames {
+		if !global.VisibleTo(s) {
+			continue
+		}
 		protocols.WlRegistry_global(s, registry, uint32(global.Id), global.Name, global.Version)
 	}
 }
@@ -40,6 +40,7 @@ func (o *WlKeyboard) AfterGetKeyboard(
 		o.Key_map_fd,
 		o.Key_map_size,
 	)
+	KeyboardFocus.KeyboardCreated(s, object_id)
 }
 
 func MakeWlKeyboard() *protocols.WlKeyboard {
@@ -22,10 +22,10 @@ func (o *WlOutput) OnBind(
 	newID := protocols.ObjectID[protocols.WlOutput](newId_any)
 	o.Version = version
 
-	protocols.WlOutput_scale(s, o.Version, newID, 1)
+	protocols.WlOutput_scale(s, newID, 1)
 
-	protocols.WlOutput_name(s, o.Version, newID, "term.everything Virtual Monitor")
-	protocols.WlOutput_description(s, o.Version, newID, "The best monitor")
+	protocols.WlOutput_name(s, newID, "term.everything Virtual Monitor")
+	protocols.WlOutput_description(s, newID, "The best monitor")
 
 	protocols.WlOutput_geometry(
 		s,
@@ -49,7 +49,7 @@ func (o *WlOutput) OnBind(
 		60_000,
 	)
 
-	protocols.WlOutput_done(s, version, newID)
+	protocols.WlOutput_done(s, newID)
 }
 
 func MakeWlOutput() *protocols.WlOutput {
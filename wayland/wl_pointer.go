@@ -2,6 +2,7 @@ package wayland
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/mmulet/term.everything/wayland/protocols"
 )
@@ -12,6 +13,35 @@ type WlPointer struct {
 
 	WindowX float32
 	WindowY float32
+
+	/**
+	 * Guards Focus, which is changed by the input
+	 * loop and cleared by the clients' own requests.
+	 */
+	Access sync.Mutex
+	/**
+	 * The surface the pointer is over, the only
+	 * one that gets motion, button and axis events.
+	 */
+	Focus *FocusedSurface
+	/**
+	 * Last position within Focus, in surface local coordinates
+	 */
+	FocusX float32
+	FocusY float32
+	/**
+	 * Where Focus's origin is in desktop coordinates
+	 */
+	FocusOriginX float32
+	FocusOriginY float32
+
+	/**
+	 * While a button is held the focus doesn't change
+	 * (an implicit grab), so dragging a scrollbar
+	 * keeps working outside the window. Keyed by
+	 * button code, so a repeated press counts once.
+	 */
+	ButtonsHeld map[uint32]bool
 }
 
 func (p *WlPointer) WlPointer_set_cursor(
@@ -31,7 +61,10 @@ func (p *WlPointer) WlPointer_set_cursor(
 	//   return;
 	// }
 
+	p.Access.Lock()
 	pointerSurfaceID, ok := p.PointerSurfaceID[s]
+	p.PointerSurfaceID[s] = surface_id
+	p.Access.Unlock()
 	if ok && !AreSame(pointerSurfaceID, surface_id) {
 		if oldPointerSurface := GetWlSurfaceObject(s, *pointerSurfaceID); oldPointerSurface != nil {
 			oldPointerSurface.Texture = nil
@@ -44,8 +77,6 @@ func (p *WlPointer) WlPointer_set_cursor(
 		}
 	}
 
-	p.PointerSurfaceID[s] = surface_id
-
 	if surface_id == nil {
 		return
 	}
@@ -77,12 +108,247 @@ func (p *WlPointer) WlPointer_set_cursor(
 
 }
 
-func (p *WlPointer) AfterGetPointer(_ protocols.ClientState, _ protocols.ObjectID[protocols.WlPointer]) {
-	/** @TODO: Implement wl_pointer_set_cursor */
-	/**
-	 * @TODO probably pointer.enter
-	 */
-	// this.last_pointer_enter_serial += 1;
+/**
+ * Move the pointer to x, y (desktop coordinates), sending
+ * enter and leave if it crossed onto another surface and
+ * motion in surface local coordinates otherwise.
+ * The clients must be locked.
+ */
+func (p *WlPointer) Motion(clients []*Client, x, y float32, time uint32) {
+	p.WindowX = x
+	p.WindowY = y
+	p.Access.Lock()
+	defer p.Access.Unlock()
+
+	var hit *SurfaceHit
+	if len(p.ButtonsHeld) > 0 && p.Focus != nil {
+		hit = &SurfaceHit{
+			Client:    p.Focus.Client.(*Client),
+			SurfaceID: p.Focus.SurfaceID,
+			X:         x - p.FocusOriginX,
+			Y:         y - p.FocusOriginY,
+		}
+	} else {
+		hit = PopupGrab.Filter(FindSurfaceAt(clients, x, y))
+	}
+
+	if !p.setFocus(hit) || hit == nil {
+		return
+	}
+	p.FocusX = hit.X
+	p.FocusY = hit.Y
+	for pointerID := range protocols.GetGlobalWlPointerBinds(hit.Client) {
+		protocols.WlPointer_motion(hit.Client, pointerID, time, hit.X, hit.Y)
+		protocols.WlPointer_frame(hit.Client, pointerID)
+	}
+}
+
+/**
+ * Check what is under the pointer again, without it moving.
+ * For when surfaces appear, move or go away underneath it.
+ * The clients must be locked.
+ */
+func (p *WlPointer) Refresh(clients []*Client) {
+	p.Access.Lock()
+	defer p.Access.Unlock()
+	if len(p.ButtonsHeld) > 0 && p.Focus != nil {
+		return
+	}
+	p.setFocus(PopupGrab.Filter(FindSurfaceAt(clients, p.WindowX, p.WindowY)))
+}
+
+/**
+ * Send leave if the pointer is over one of s's
+ * surfaces. s must be locked.
+ */
+func (p *WlPointer) Leave(s protocols.ClientState) {
+	p.Access.Lock()
+	defer p.Access.Unlock()
+	if p.Focus != nil && p.Focus.Client == s {
+		p.setFocus(nil)
+	}
+}
+
+/**
+ * Returns true if the focus did not change,
+ * (and so a motion event should be sent).
+ */
+func (p *WlPointer) setFocus(hit *SurfaceHit) bool {
+	if hit != nil && p.Focus != nil &&
+		p.Focus.Client == hit.Client && p.Focus.SurfaceID == hit.SurfaceID {
+		return true
+	}
+	if p.Focus == nil && hit == nil {
+		return true
+	}
+	if old := p.Focus; old != nil {
+		p.Focus = nil
+		/**
+		 * The new surface never got the presses
+		 */
+		clear(p.ButtonsHeld)
+		for pointerID := range protocols.GetGlobalWlPointerBinds(old.Client) {
+			protocols.WlPointer_leave(old.Client, pointerID, NextSerial(), old.SurfaceID)
+			protocols.WlPointer_frame(old.Client, pointerID)
+		}
+	}
+	if hit == nil {
+		return false
+	}
+	p.Focus = &FocusedSurface{Client: hit.Client, SurfaceID: hit.SurfaceID}
+	p.FocusX = hit.X
+	p.FocusY = hit.Y
+	p.FocusOriginX = p.WindowX - hit.X
+	p.FocusOriginY = p.WindowY - hit.Y
+	for pointerID := range protocols.GetGlobalWlPointerBinds(hit.Client) {
+		protocols.WlPointer_enter(hit.Client, pointerID, NextSerial(), hit.SurfaceID, hit.X, hit.Y)
+		protocols.WlPointer_frame(hit.Client, pointerID)
+	}
+	return false
+}
+
+/**
+ * The surface the client set as its cursor, or nil
+ */
+func (p *WlPointer) CursorSurface(s protocols.ClientState) *protocols.ObjectID[protocols.WlSurface] {
+	p.Access.Lock()
+	defer p.Access.Unlock()
+	return p.PointerSurfaceID[s]
+}
+
+/**
+ * The client the pointer is over, or nil
+ */
+func (p *WlPointer) FocusedClient() protocols.ClientState {
+	p.Access.Lock()
+	defer p.Access.Unlock()
+	if p.Focus == nil {
+		return nil
+	}
+	return p.Focus.Client
+}
+
+/**
+ * Send a button to the surface under the pointer.
+ * The clients must be locked.
+ */
+func (p *WlPointer) Button(button uint32, state protocols.WlPointerButtonState_enum, time uint32) {
+	p.Access.Lock()
+	defer p.Access.Unlock()
+	if state == protocols.WlPointerButtonState_enum_pressed {
+		p.ButtonsHeld[button] = true
+	} else {
+		if !p.ButtonsHeld[button] {
+			/**
+			 * Its press went to a surface that lost focus
+			 * since, or it was already released
+			 */
+			return
+		}
+		delete(p.ButtonsHeld, button)
+	}
+	p.sendButton(button, state, time)
+}
+
+/**
+ * Release every held button, for when the terminal
+ * doesn't say which button was released.
+ * The clients must be locked.
+ */
+func (p *WlPointer) ReleaseAll(time uint32) {
+	p.Access.Lock()
+	defer p.Access.Unlock()
+	for button := range p.ButtonsHeld {
+		p.sendButton(button, protocols.WlPointerButtonState_enum_released, time)
+	}
+	clear(p.ButtonsHeld)
+}
+
+func (p *WlPointer) sendButton(button uint32, state protocols.WlPointerButtonState_enum, time uint32) {
+	if p.Focus == nil {
+		return
+	}
+	s := p.Focus.Client
+	for pointerID := range protocols.GetGlobalWlPointerBinds(s) {
+		protocols.WlPointer_button(s, pointerID, NextSerial(), time, button, state)
+		protocols.WlPointer_frame(s, pointerID)
+	}
+}
+
+/**
+ * A drag took over the pointer, the
+ * button release goes to the drop instead.
+ */
+func (p *WlPointer) EndImplicitGrab() {
+	p.Access.Lock()
+	defer p.Access.Unlock()
+	clear(p.ButtonsHeld)
+}
+
+/**
+ * Scroll the surface under the pointer.
+ * The clients must be locked.
+ */
+func (p *WlPointer) Axis(axis protocols.WlPointerAxis_enum, amount float32, time uint32) {
+	p.Access.Lock()
+	defer p.Access.Unlock()
+	if p.Focus == nil {
+		return
+	}
+	s := p.Focus.Client
+	for pointerID := range protocols.GetGlobalWlPointerBinds(s) {
+		protocols.WlPointer_axis(s, pointerID, time, axis, amount)
+		protocols.WlPointer_frame(s, pointerID)
+	}
+}
+
+/**
+ * The surface of s under the pointer, and where
+ * on it the pointer is. ok is false if it isn't over s.
+ */
+func (p *WlPointer) Over(s protocols.ClientState) (surface_id protocols.ObjectID[protocols.WlSurface], x, y float32, ok bool) {
+	p.Access.Lock()
+	defer p.Access.Unlock()
+	if p.Focus == nil || p.Focus.Client != s {
+		return 0, 0, 0, false
+	}
+	return p.Focus.SurfaceID, p.FocusX, p.FocusY, true
+}
+
+/**
+ * The surface is gone, so it can't be in a leave event.
+ */
+func (p *WlPointer) SurfaceDestroyed(s protocols.ClientState, surface_id protocols.ObjectID[protocols.WlSurface]) {
+	p.Access.Lock()
+	defer p.Access.Unlock()
+	if p.Focus != nil && p.Focus.Client == s && p.Focus.SurfaceID == surface_id {
+		p.Focus = nil
+		clear(p.ButtonsHeld)
+	}
+}
+
+func (p *WlPointer) RemoveClient(s protocols.ClientState) {
+	p.Access.Lock()
+	defer p.Access.Unlock()
+	delete(p.PointerSurfaceID, s)
+	if p.Focus != nil && p.Focus.Client == s {
+		p.Focus = nil
+		clear(p.ButtonsHeld)
+	}
+}
+
+/**
+ * If the pointer is already over one of the
+ * client's surfaces, tell the new wl_pointer.
+ */
+func (p *WlPointer) AfterGetPointer(s protocols.ClientState, pointerID protocols.ObjectID[protocols.WlPointer]) {
+	p.Access.Lock()
+	defer p.Access.Unlock()
+	if p.Focus == nil || p.Focus.Client != s {
+		return
+	}
+	protocols.WlPointer_enter(s, pointerID, NextSerial(), p.Focus.SurfaceID, p.FocusX, p.FocusY)
+	protocols.WlPointer_frame(s, pointerID)
 }
 
 func (p *WlPointer) WlPointer_release(
@@ -110,4 +376,5 @@ func MakeWlPointer() *protocols.WlPointer {
 
 var Pointer = WlPointer{
 	PointerSurfaceID: make(map[protocols.ClientState]*protocols.ObjectID[protocols.WlSurface]),
+	ButtonsHeld:      make(map[uint32]bool),
 }
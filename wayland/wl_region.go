@@ -10,6 +10,7 @@ func auto_release(s protocols.ClientState,
 }
 
 type WlRegion struct {
+	Region Region
 }
 
 func (r *WlRegion) WlRegion_destroy(
@@ -27,20 +28,19 @@ func (r *WlRegion) WlRegion_add(
 	width int32,
 	height int32,
 ) {
-	// TODO: Implement proper region union semantics.
+	r.Region.Add(ClampRect(Rect{X: x, Y: y, Width: width, Height: height}))
 }
 
 func (r *WlRegion) WlRegion_subtract(
 	_ protocols.ClientState,
 	_ protocols.ObjectID[protocols.WlRegion],
-	_x int32,
-	_y int32,
-	_width int32,
-	_height int32,
+	x int32,
+	y int32,
+	width int32,
+	height int32,
 ) {
-	// TODO: Implement proper region subtraction semantics.
+	r.Region.Subtract(ClampRect(Rect{X: x, Y: y, Width: width, Height: height}))
 }
-
 func (r *WlRegion) OnBind(
 	s protocols.ClientState,
 	_ protocols.AnyObjectID,
@@ -7,7 +7,11 @@ import (
 type WlRegistryDelegateImpl struct{}
 
 func (w *WlRegistryDelegateImpl) WlRegistry_bind(s protocols.ClientState, object_id protocols.ObjectID[protocols.WlRegistry], name uint32, idInterface string, idVersion uint32, idID protocols.AnyObjectID) {
-	object := s.GetObject(protocols.AnyObjectID(name))
+	if !protocols.GlobalVisibleTo(s, protocols.GlobalID(name)) {
+		SendError(s, object_id, protocols.WlDisplayError_enum_invalid_object, "invalid global "+idInterface)
+		return
+	}
+	object := GetGlobalObjectByID(name)
 	s.AddObject(idID, object)
 	version := protocols.Version(idVersion)
 
@@ -26,6 +30,8 @@ func (w *WlRegistryDelegateImpl) WlRegistry_bind(s protocols.ClientState, object
 		s.AddGlobalWlTouchBind(protocols.ObjectID[protocols.WlTouch](idID), version)
 	case uint32(protocols.GlobalID_WlDataDevice):
 		s.AddGlobalWlDataDeviceBind(protocols.ObjectID[protocols.WlDataDevice](idID), version)
+	case uint32(protocols.GlobalID_WlDataDeviceManager):
+		s.AddGlobalWlDataDeviceManagerBind(protocols.ObjectID[protocols.WlDataDeviceManager](idID), version)
 	case uint32(protocols.GlobalID_ZwpXwaylandKeyboardGrabManagerV1):
 		s.AddGlobalZwpXwaylandKeyboardGrabManagerV1Bind(protocols.ObjectID[protocols.ZwpXwaylandKeyboardGrabManagerV1](idID), version)
 		// const set = s.global_binds.get(name) ?? new Set();
@@ -15,6 +15,7 @@ func (w *WlSeat) WlSeat_get_pointer(
 ) {
 	s.AddGlobalWlPointerBind(id, protocols.Version(w.Version))
 	AddObject(s, id, Global_WlPointer)
+	Pointer.AfterGetPointer(s, id)
 }
 
 func (w *WlSeat) WlSeat_get_keyboard(
@@ -58,7 +59,7 @@ func (w *WlSeat) OnBind(
 		newID,
 		protocols.WlSeatCapability_enum_pointer|protocols.WlSeatCapability_enum_keyboard,
 	)
-	protocols.WlSeat_name(s, version, newID, "seat0")
+	protocols.WlSeat_name(s, newID, "seat0")
 }
 
 func MakeWLSeat() *protocols.WlSeat {
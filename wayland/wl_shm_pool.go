@@ -2,6 +2,7 @@ package wayland
 
 import (
 	"fmt"
+	"syscall"
 
 	"github.com/mmulet/term.everything/wayland/protocols"
 )
@@ -52,19 +53,26 @@ func (p *WlShmPool) WlShmPool_create_buffer(
 	}
 }
 
+/**
+ * Unmap the pool, and close its fd, which
+ * was kept open in case the pool was resized.
+ */
 func (p *WlShmPool) OnDestroyShmPool(s protocols.ClientState, objectID protocols.ObjectID[protocols.WlShmPool]) {
 	if memap, ok := p.MemMaps[objectID]; ok {
 		memap.Unmap()
+		syscall.Close(int(memap.FileDescriptor))
+		delete(p.MemMaps, objectID)
 	}
 	p.MapState = MapStateDestroyed
-	RemoveObject(s, objectID)
 }
 
 /**
  * This can be called by either on the buffer delegate or the pool delegate
  * @param s
  * @param _object_id Check This!! to see if it is the buffer id or the pool id
- * @returns false because wl_shm_pool hndles remove objet by itself
+ * @returns true, the pool's id goes away now. Its buffers
+ * share this delegate, so the memory stays mapped until
+ * the last of them is destroyed.
  */
 func (p *WlShmPool) WlShmPool_destroy(
 	s protocols.ClientState,
@@ -73,14 +81,14 @@ func (p *WlShmPool) WlShmPool_destroy(
 	buffersEmpty := len(p.Buffers) <= 0
 	switch p.MapState {
 	case MapStateDestroyed, MapStateDestroyWhenBuffersEmpty:
-		return false
+		return true
 	case MapStateMmapped:
 		if buffersEmpty {
 			p.OnDestroyShmPool(s, objectID)
-			return false
+			return true
 		}
 		p.MapState = MapStateDestroyWhenBuffersEmpty
-		return false
+		return true
 	default:
 		panic("unexpected MapState")
 	}
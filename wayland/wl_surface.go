@@ -53,22 +53,38 @@ type WlSurface struct {
 	/**
 	 * Null means infinite, (ie we can accept input from everywhere)
 	 */
-	InputRegion *protocols.ObjectID[protocols.WlRegion]
+	InputRegion *Region
 	/**
 	 * Unlink opaque region, null means empty!
 	 */
-	OpaqueRegion *protocols.ObjectID[protocols.WlRegion]
+	OpaqueRegion *Region
 
 	PendingUpdate SurfaceUpdate
 
 	Offset Point
 
 	/**
-	 * Don't care about regions for now.
-	 * Just need to clear this when the surface has
-	 * been drawn.
+	 * Damage of the commit being applied, in buffer
+	 * coordinates. Tells CopyBufferToWlSurfaceTexture
+	 * what to copy, nil means nothing changed.
 	 */
-	Damaged bool
+	BufferDamage *Region
+	/**
+	 * Everything that changed in the texture since
+	 * the Desktop last drew the surface, in buffer
+	 * coordinates. The Desktop clears it.
+	 */
+	Damage *Region
+}
+
+/**
+ * Add r to the damage the Desktop has not drawn yet
+ */
+func (w *WlSurface) AddDamage(r Rect) {
+	if w.Damage == nil {
+		w.Damage = &Region{}
+	}
+	w.Damage.Add(r)
 }
 
 func (w *WlSurface) ClearRoleData() {
@@ -107,6 +123,11 @@ func (w *WlSurface) WlSurface_destroy(
 
 	// this.destroy_texture(s, object_id);
 
+	KeyboardFocus.SurfaceDestroyed(s, object_id)
+	Pointer.SurfaceDestroyed(s, object_id)
+	XWindows.SurfaceDestroyed(s, object_id)
+	delete(s.DrawableSurfaces(), object_id)
+
 	if !w.HasRoleData() {
 		return true
 	}
@@ -168,19 +189,34 @@ func (w *WlSurface) WlSurface_frame(
 }
 
 func (w *WlSurface) WlSurface_set_opaque_region(
-	_ protocols.ClientState,
+	s protocols.ClientState,
 	_ protocols.ObjectID[protocols.WlSurface],
 	region *protocols.ObjectID[protocols.WlRegion],
 ) {
-	w.PendingUpdate.OpaqueRegion = region
+	w.PendingUpdate.OpaqueRegion = CopyRegion(s, region)
 }
 
 func (w *WlSurface) WlSurface_set_input_region(
-	_ protocols.ClientState,
+	s protocols.ClientState,
 	_ protocols.ObjectID[protocols.WlSurface],
 	region *protocols.ObjectID[protocols.WlRegion],
 ) {
-	w.PendingUpdate.InputRegion = region
+	w.PendingUpdate.InputRegion = CopyRegion(s, region)
+}
+
+/**
+ * The region can be destroyed or changed right after
+ * the request, so the surface keeps its own copy.
+ */
+func CopyRegion(s protocols.ClientState, region_id *protocols.ObjectID[protocols.WlRegion]) *RegionUpdate {
+	if region_id == nil {
+		return &RegionUpdate{}
+	}
+	region := GetWlRegionObject(s, *region_id)
+	if region == nil {
+		return &RegionUpdate{Region: &Region{}}
+	}
+	return &RegionUpdate{Region: region.Region.Clone()}
 }
 
 func (w *WlSurface) WlSurface_commit(
@@ -0,0 +1,58 @@
+package wayland
+
+import (
+	"syscall"
+
+	"github.com/mmulet/term.everything/wayland/protocols"
+)
+
+type WpSecurityContextManagerV1 struct{}
+
+func (m *WpSecurityContextManagerV1) WpSecurityContextManagerV1_destroy(
+	_ protocols.ClientState,
+	_ protocols.ObjectID[protocols.WpSecurityContextManagerV1],
+) bool {
+	return true
+}
+
+func (m *WpSecurityContextManagerV1) WpSecurityContextManagerV1_create_listener(
+	s protocols.ClientState,
+	object_id protocols.ObjectID[protocols.WpSecurityContextManagerV1],
+	id protocols.ObjectID[protocols.WpSecurityContextV1],
+	listen_fd *protocols.FileDescriptor,
+	close_fd *protocols.FileDescriptor,
+) {
+	if listen_fd == nil || close_fd == nil {
+		SendError(s, object_id, protocols.WpSecurityContextManagerV1Error_enum_invalid_listen_fd, "missing file descriptor")
+		return
+	}
+	if s.GetSecurityContext() != nil {
+		syscall.Close(int(*listen_fd))
+		syscall.Close(int(*close_fd))
+		SendError(s, object_id, protocols.WpSecurityContextManagerV1Error_enum_nested, "nested security contexts are forbidden")
+		return
+	}
+	accepting, err := syscall.GetsockoptInt(int(*listen_fd), syscall.SOL_SOCKET, syscall.SO_ACCEPTCONN)
+	if err != nil || accepting == 0 {
+		syscall.Close(int(*listen_fd))
+		syscall.Close(int(*close_fd))
+		SendError(s, object_id, protocols.WpSecurityContextManagerV1Error_enum_invalid_listen_fd, "listen_fd is not a listening socket")
+		return
+	}
+	AddObject(s, id, MakeWpSecurityContextV1(*listen_fd, *close_fd))
+}
+
+func (m *WpSecurityContextManagerV1) OnBind(
+	_ protocols.ClientState,
+	_ protocols.AnyObjectID,
+	_ string,
+	_ protocols.AnyObjectID,
+	_ uint32,
+) {
+}
+
+func MakeWpSecurityContextManagerV1() *protocols.WpSecurityContextManagerV1 {
+	return &protocols.WpSecurityContextManagerV1{
+		Delegate: &WpSecurityContextManagerV1{},
+	}
+}
@@ -0,0 +1,196 @@
+package wayland
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/mmulet/term.everything/wayland/protocols"
+)
+
+/**
+ * Globals hidden from sandboxed clients, by name.
+ * Set from the command line before any client connects.
+ */
+var SandboxHiddenGlobals = map[string]bool{}
+
+/**
+ * A connection to a socket made with wp_security_context_v1
+ */
+type SandboxedConnection struct {
+	Conn    *net.UnixConn
+	Context *protocols.SecurityContext
+}
+
+/**
+ * Whoever reads from this makes the clients,
+ * like SocketListener.OnConnection.
+ */
+var SandboxedConnections = make(chan SandboxedConnection, 32)
+
+type WpSecurityContextV1 struct {
+	ListenFD protocols.FileDescriptor
+	CloseFD  protocols.FileDescriptor
+
+	SandboxEngine *string
+	AppID         *string
+	InstanceID    *string
+
+	/**
+	 * After commit the file descriptors
+	 * belong to the listening goroutine.
+	 */
+	Committed bool
+}
+
+func (c *WpSecurityContextV1) WpSecurityContextV1_destroy(
+	_ protocols.ClientState,
+	_ protocols.ObjectID[protocols.WpSecurityContextV1],
+) bool {
+	if !c.Committed {
+		syscall.Close(int(c.ListenFD))
+		syscall.Close(int(c.CloseFD))
+	}
+	return true
+}
+
+func (c *WpSecurityContextV1) set(
+	s protocols.ClientState,
+	object_id protocols.ObjectID[protocols.WpSecurityContextV1],
+	field **string,
+	value string,
+) {
+	if c.Committed {
+		SendError(s, object_id, protocols.WpSecurityContextV1Error_enum_already_used, "security context already committed")
+		return
+	}
+	if *field != nil {
+		SendError(s, object_id, protocols.WpSecurityContextV1Error_enum_already_set, "metadata already set")
+		return
+	}
+	*field = &value
+}
+
+func (c *WpSecurityContextV1) WpSecurityContextV1_set_sandbox_engine(
+	s protocols.ClientState,
+	object_id protocols.ObjectID[protocols.WpSecurityContextV1],
+	name string,
+) {
+	c.set(s, object_id, &c.SandboxEngine, name)
+}
+
+func (c *WpSecurityContextV1) WpSecurityContextV1_set_app_id(
+	s protocols.ClientState,
+	object_id protocols.ObjectID[protocols.WpSecurityContextV1],
+	app_id string,
+) {
+	c.set(s, object_id, &c.AppID, app_id)
+}
+
+func (c *WpSecurityContextV1) WpSecurityContextV1_set_instance_id(
+	s protocols.ClientState,
+	object_id protocols.ObjectID[protocols.WpSecurityContextV1],
+	instance_id string,
+) {
+	c.set(s, object_id, &c.InstanceID, instance_id)
+}
+
+func (c *WpSecurityContextV1) WpSecurityContextV1_commit(
+	s protocols.ClientState,
+	object_id protocols.ObjectID[protocols.WpSecurityContextV1],
+) {
+	if c.Committed {
+		SendError(s, object_id, protocols.WpSecurityContextV1Error_enum_already_used, "security context already committed")
+		return
+	}
+	c.Committed = true
+	context := &protocols.SecurityContext{
+		HiddenGlobals: maps.Clone(SandboxHiddenGlobals),
+	}
+	if c.SandboxEngine != nil {
+		context.SandboxEngine = *c.SandboxEngine
+	}
+	if c.AppID != nil {
+		context.AppID = *c.AppID
+	}
+	if c.InstanceID != nil {
+		context.InstanceID = *c.InstanceID
+	}
+	if err := ListenForSandboxedClients(c.ListenFD, c.CloseFD, context); err != nil {
+		fmt.Fprintf(os.Stderr, "wp_security_context_v1: %v\n", err)
+	}
+}
+
+/**
+ * Accept clients on listen_fd until close_fd hangs up.
+ * Takes ownership of both file descriptors.
+ */
+func ListenForSandboxedClients(
+	listen_fd protocols.FileDescriptor,
+	close_fd protocols.FileDescriptor,
+	context *protocols.SecurityContext,
+) error {
+	listenFile := os.NewFile(uintptr(listen_fd), "security-context-listen")
+	closeFile := os.NewFile(uintptr(close_fd), "security-context-close")
+	listener, err := net.FileListener(listenFile)
+	/**
+	 * FileListener has its own copy of the fd
+	 */
+	listenFile.Close()
+	if err != nil {
+		closeFile.Close()
+		return fmt.Errorf("failed to listen on the sandbox socket: %w", err)
+	}
+	unixListener, ok := listener.(*net.UnixListener)
+	if !ok {
+		listener.Close()
+		closeFile.Close()
+		return fmt.Errorf("the sandbox socket is not a unix socket")
+	}
+
+	go func() {
+		/**
+		 * Nothing is written to close_fd, reading
+		 * returns once the other end is closed.
+		 */
+		buf := make([]byte, 64)
+		for {
+			if _, err := closeFile.Read(buf); err != nil {
+				break
+			}
+		}
+		closeFile.Close()
+		unixListener.Close()
+	}()
+
+	go func() {
+		for {
+			conn, err := unixListener.AcceptUnix()
+			if err != nil {
+				return
+			}
+			SandboxedConnections <- SandboxedConnection{Conn: conn, Context: context}
+		}
+	}()
+	return nil
+}
+
+func (c *WpSecurityContextV1) OnBind(
+	_ protocols.ClientState,
+	_ protocols.AnyObjectID,
+	_ string,
+	_ protocols.AnyObjectID,
+	_ uint32,
+) {
+}
+
+func MakeWpSecurityContextV1(listen_fd protocols.FileDescriptor, close_fd protocols.FileDescriptor) *protocols.WpSecurityContextV1 {
+	return &protocols.WpSecurityContextV1{
+		Delegate: &WpSecurityContextV1{
+			ListenFD: listen_fd,
+			CloseFD:  close_fd,
+		},
+	}
+}
@@ -8,13 +8,19 @@ type XdgPopup struct {
 	Version uint32
 	Parent  *protocols.ObjectID[protocols.XdgSurface]
 	State   XdgPositionerState
+
+	/**
+	 * Where the popup was last configured, relative
+	 * to its parent's window geometry
+	 */
+	Geometry Rect
+
 	/**
-	 * Only one instead of a queue because if multiple
-	 * position requests are sent we can ignore
-	 * all but the last one
+	 * Took an explicit grab, and if it was
+	 * dismissed since (popup_done was sent)
 	 */
-	pendingPosition       *XdgPositionerState
-	pendingPositionSerial *uint32
+	Grabbed   bool
+	Dismissed bool
 }
 
 func (x *XdgPopup) XdgPopup_destroy(
@@ -22,6 +28,14 @@ func (x *XdgPopup) XdgPopup_destroy(
 	object_id protocols.ObjectID[protocols.XdgPopup],
 ) bool {
 	surface := GetSurfaceFromRole(s, object_id)
+	PopupGrab.PopupDestroyed(s, object_id)
+	if surface_id := GetSurfaceIDFromRole(s, object_id); surface_id != nil {
+		KeyboardFocus.SurfaceUnmapped(s, *surface_id)
+		/**
+		 * Destroying the role object unmaps the surface
+		 */
+		delete(s.DrawableSurfaces(), *surface_id)
+	}
 	UnregisterRoleToSurface(s, object_id)
 	if surface == nil {
 		return true
@@ -39,12 +53,56 @@ func (x *XdgPopup) XdgPopup_destroy(
 }
 
 func (x *XdgPopup) XdgPopup_grab(
-	_ protocols.ClientState,
-	_ protocols.ObjectID[protocols.XdgPopup],
+	s protocols.ClientState,
+	object_id protocols.ObjectID[protocols.XdgPopup],
 	_ protocols.ObjectID[protocols.WlSeat],
 	_ uint32,
 ) {
-	/** @TODO: Implement xdg_popup_grab */
+	surface := GetSurfaceFromRole(s, object_id)
+	if surface == nil {
+		return
+	}
+	if surface.Texture != nil {
+		SendError(
+			s,
+			object_id,
+			protocols.XdgPopupError_enum_invalid_grab,
+			"popup grabbed after it was mapped",
+		)
+		return
+	}
+	var parent_popup *protocols.ObjectID[protocols.XdgPopup]
+	if x.Parent != nil {
+		if parent_surface := GetSurfaceFromRole(s, *x.Parent); parent_surface != nil {
+			if role, ok := parent_surface.Role.(*SurfaceRoleXdgPopup); ok {
+				parent_popup = role.Data
+			}
+		}
+	}
+	if parent_popup != nil {
+		parent := GetXdgPopupObject(s, *parent_popup)
+		if parent == nil || !parent.Grabbed {
+			SendError(
+				s,
+				object_id,
+				protocols.XdgPopupError_enum_invalid_grab,
+				"the parent popup did not take a grab",
+			)
+			return
+		}
+		if parent.Dismissed {
+			/**
+			 * From the docs:
+			 * If the parent is a grabbing popup which has already
+			 * been dismissed, this popup will be immediately dismissed.
+			 */
+			x.Dismissed = true
+			protocols.XdgPopup_popup_done(s, object_id)
+			return
+		}
+	}
+	x.Grabbed = true
+	PopupGrab.Grab(s, object_id, parent_popup)
 }
 
 func (x *XdgPopup) XdgPopup_reposition(
@@ -57,38 +115,15 @@ func (x *XdgPopup) XdgPopup_reposition(
 	if positioner == nil {
 		return
 	}
-	new_state := positioner.state
-	st := new_state // copy
-	x.pendingPosition = &st
-	x.pendingPositionSerial = &token
-
-	protocols.XdgPopup_repositioned(s, x.Version, object_id, token)
+	x.State = positioner.state
 
 	/**
-	 * @TODO figure out what
-	 * these values are
+	 * From the docs:
+	 * the compositor responds with an xdg_popup.repositioned
+	 * event, followed by xdg_popup.configure and xdg_surface.configure
 	 */
-	protocols.XdgPopup_configure(s, object_id, 0, 0, int32(VirtualMonitorSize.Width), int32(VirtualMonitorSize.Height))
-
-	surface := GetSurfaceFromRole(s, object_id)
-	if surface == nil {
-		return
-	}
-
-	if surface.XdgSurfaceState == nil {
-		return
-	}
-
-	xdg_surface_state := GetXdgSurfaceObject(s, *surface.XdgSurfaceState)
-	if xdg_surface_state == nil {
-		return
-	}
-
-	go func() {
-		xdg_surface_state.configure(s)
-		// reposition the popup somehow
-	}()
-
+	protocols.XdgPopup_repositioned(s, object_id, token)
+	ConfigurePopup(s, object_id)
 }
 
 func (x *XdgPopup) OnBind(
@@ -100,6 +135,73 @@ func (x *XdgPopup) OnBind(
 ) {
 }
 
+/**
+ * Move the popup's surface to where it was configured,
+ * relative to its parent's window geometry (the parent
+ * is already in desktop coordinates). s must be locked.
+ */
+func PlacePopup(s protocols.ClientState, popup_id protocols.ObjectID[protocols.XdgPopup]) {
+	surface := GetSurfaceFromRole(s, popup_id)
+	popup := GetXdgPopupObject(s, popup_id)
+	if surface == nil || popup == nil {
+		return
+	}
+	x, y := popup.ParentOrigin(s)
+	geometry := WindowGeometry(s, surface)
+	surface.Position.X = x + popup.Geometry.X - geometry.X
+	surface.Position.Y = y + popup.Geometry.Y - geometry.Y
+}
+
+/**
+ * Where the parent's window geometry starts, in
+ * desktop coordinates. s must be locked.
+ */
+func (x *XdgPopup) ParentOrigin(s protocols.ClientState) (int32, int32) {
+	if x.Parent == nil {
+		return 0, 0
+	}
+	parent := GetSurfaceFromRole(s, *x.Parent)
+	if parent == nil {
+		return 0, 0
+	}
+	geometry := WindowGeometry(s, parent)
+	return parent.Position.X + geometry.X, parent.Position.Y + geometry.Y
+}
+
+/**
+ * Place the popup with its positioner, so it stays on the
+ * virtual monitor, and send xdg_popup.configure and
+ * xdg_surface.configure without waiting for the ack.
+ * s must be locked.
+ */
+func ConfigurePopup(s protocols.ClientState, popup_id protocols.ObjectID[protocols.XdgPopup]) {
+	popup := GetXdgPopupObject(s, popup_id)
+	surface := GetSurfaceFromRole(s, popup_id)
+	if popup == nil || surface == nil || surface.XdgSurfaceState == nil {
+		return
+	}
+	xdg_surface := GetXdgSurfaceObject(s, *surface.XdgSurfaceState)
+	if xdg_surface == nil {
+		return
+	}
+	x, y := popup.ParentOrigin(s)
+	popup.Geometry = popup.State.Place(Rect{
+		X:      -x,
+		Y:      -y,
+		Width:  int32(VirtualMonitorSize.Width),
+		Height: int32(VirtualMonitorSize.Height),
+	})
+	protocols.XdgPopup_configure(
+		s,
+		popup_id,
+		popup.Geometry.X, popup.Geometry.Y,
+		popup.Geometry.Width, popup.Geometry.Height,
+	)
+	serial := xdg_surface.LatestSerial
+	xdg_surface.LatestSerial++
+	protocols.XdgSurface_configure(s, xdg_surface.XdgSurfaceID, serial)
+}
+
 func MakeXdgPopup(
 	version uint32,
 	parent *protocols.ObjectID[protocols.XdgSurface],
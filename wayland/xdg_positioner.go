@@ -41,23 +41,41 @@ func (x *XdgPositioner) XdgPositioner_destroy(
 }
 
 func (x *XdgPositioner) XdgPositioner_set_size(
-	_ protocols.ClientState,
-	_ protocols.ObjectID[protocols.XdgPositioner],
+	s protocols.ClientState,
+	object_id protocols.ObjectID[protocols.XdgPositioner],
 	width int32,
 	height int32,
 ) {
+	if width <= 0 || height <= 0 {
+		SendError(
+			s,
+			object_id,
+			protocols.XdgPositionerError_enum_invalid_input,
+			"size must be positive",
+		)
+		return
+	}
 	x.state.Width = width
 	x.state.Height = height
 }
 
 func (x *XdgPositioner) XdgPositioner_set_anchor_rect(
-	_ protocols.ClientState,
-	_ protocols.ObjectID[protocols.XdgPositioner],
+	s protocols.ClientState,
+	object_id protocols.ObjectID[protocols.XdgPositioner],
 	ax int32,
 	ay int32,
 	aw int32,
 	ah int32,
 ) {
+	if aw < 0 || ah < 0 {
+		SendError(
+			s,
+			object_id,
+			protocols.XdgPositionerError_enum_invalid_input,
+			"anchor rect size must not be negative",
+		)
+		return
+	}
 	x.state.AnchorRect = anchorRect{X: ax, Y: ay, Width: aw, Height: ah}
 }
 
@@ -82,7 +100,7 @@ func (x *XdgPositioner) XdgPositioner_set_constraint_adjustment(
 	_ protocols.ObjectID[protocols.XdgPositioner],
 	adj protocols.XdgPositionerConstraintAdjustment_enum,
 ) {
-	/** @TODO: Implement xdg_positioner_set_constraint_adjustment */
+	x.state.ConstraintAdjustment = adj
 }
 
 func (x *XdgPositioner) XdgPositioner_set_offset(
@@ -134,6 +152,123 @@ func (x *XdgPositioner) OnBind(
 ) {
 }
 
+/**
+ * Which way an anchor or a gravity points on one axis,
+ * -1 for left (or top), 0 for the center, 1 for right (or bottom).
+ * Anchor and gravity enums have the same values.
+ */
+func positionerDirections(value uint32) (x, y int32) {
+	switch value {
+	case 1:
+		return 0, -1
+	case 2:
+		return 0, 1
+	case 3:
+		return -1, 0
+	case 4:
+		return 1, 0
+	case 5:
+		return -1, -1
+	case 6:
+		return -1, 1
+	case 7:
+		return 1, -1
+	case 8:
+		return 1, 1
+	}
+	return 0, 0
+}
+
+/**
+ * Where the popup goes, before constraints. The anchor
+ * picks a point on the anchor rect, the popup extends
+ * from it toward the gravity.
+ */
+func (p XdgPositionerState) unconstrained(anchor_x, anchor_y, gravity_x, gravity_y int32) Rect {
+	point_x := p.AnchorRect.X + p.AnchorRect.Width*(anchor_x+1)/2
+	point_y := p.AnchorRect.Y + p.AnchorRect.Height*(anchor_y+1)/2
+	return Rect{
+		X:      point_x + p.Offset.X + p.Width*(gravity_x-1)/2,
+		Y:      point_y + p.Offset.Y + p.Height*(gravity_y-1)/2,
+		Width:  p.Width,
+		Height: p.Height,
+	}
+}
+
+/**
+ * The popup's geometry, relative to the parent's window
+ * geometry. bounds (in the same coordinates) is the area
+ * it has to fit in. Like the spec says, when it doesn't fit
+ * it is flipped, then slid, then resized, on each axis
+ * separately, as far as the constraint adjustment allows.
+ */
+func (p XdgPositionerState) Place(bounds Rect) Rect {
+	anchor_x, anchor_y := positionerDirections(uint32(p.Anchor))
+	gravity_x, gravity_y := positionerDirections(uint32(p.Gravity))
+	geometry := p.unconstrained(anchor_x, anchor_y, gravity_x, gravity_y)
+	adjust := p.ConstraintAdjustment
+
+	if outside(geometry.X, geometry.Width, bounds.X, bounds.Width) {
+		if adjust&protocols.XdgPositionerConstraintAdjustment_enum_flip_x != 0 {
+			flipped := p.unconstrained(-anchor_x, anchor_y, -gravity_x, gravity_y)
+			if !outside(flipped.X, flipped.Width, bounds.X, bounds.Width) {
+				geometry.X = flipped.X
+			}
+		}
+		if adjust&protocols.XdgPositionerConstraintAdjustment_enum_slide_x != 0 {
+			geometry.X = slide(geometry.X, geometry.Width, bounds.X, bounds.Width)
+		}
+		if adjust&protocols.XdgPositionerConstraintAdjustment_enum_resize_x != 0 {
+			geometry.X, geometry.Width = resize(geometry.X, geometry.Width, bounds.X, bounds.Width)
+		}
+	}
+	if outside(geometry.Y, geometry.Height, bounds.Y, bounds.Height) {
+		if adjust&protocols.XdgPositionerConstraintAdjustment_enum_flip_y != 0 {
+			flipped := p.unconstrained(anchor_x, -anchor_y, gravity_x, -gravity_y)
+			if !outside(flipped.Y, flipped.Height, bounds.Y, bounds.Height) {
+				geometry.Y = flipped.Y
+			}
+		}
+		if adjust&protocols.XdgPositionerConstraintAdjustment_enum_slide_y != 0 {
+			geometry.Y = slide(geometry.Y, geometry.Height, bounds.Y, bounds.Height)
+		}
+		if adjust&protocols.XdgPositionerConstraintAdjustment_enum_resize_y != 0 {
+			geometry.Y, geometry.Height = resize(geometry.Y, geometry.Height, bounds.Y, bounds.Height)
+		}
+	}
+	return geometry
+}
+
+/**
+ * Does start, length stick out of bounds_start, bounds_length
+ */
+func outside(start, length, bounds_start, bounds_length int32) bool {
+	return start < bounds_start || start+length > bounds_start+bounds_length
+}
+
+/**
+ * Move start so it fits, if it is too
+ * big it lines up with the start of the bounds.
+ */
+func slide(start, length, bounds_start, bounds_length int32) int32 {
+	if start+length > bounds_start+bounds_length {
+		start = bounds_start + bounds_length - length
+	}
+	return max(start, bounds_start)
+}
+
+/**
+ * Cut off the part that sticks out, unless nothing would be left
+ */
+func resize(start, length, bounds_start, bounds_length int32) (int32, int32) {
+	new_start := max(start, bounds_start)
+	new_end := min(start+length, bounds_start+bounds_length)
+	if new_end <= new_start {
+		return start, length
+	}
+	return new_start, new_end - new_start
+}
+
 func MakeXdgPositioner() *protocols.XdgPositioner {
 	return &protocols.XdgPositioner{
 		Delegate: &XdgPositioner{},
@@ -2,7 +2,6 @@ package wayland
 
 import (
 	"fmt"
-	"time"
 
 	"github.com/mmulet/term.everything/wayland/protocols"
 )
@@ -17,27 +16,10 @@ type XdgWindowGeometry struct {
 type XdgSurface struct {
 	Version        uint32
 	XdgSurfaceID   protocols.ObjectID[protocols.XdgSurface]
-	OnConfigure    map[uint32]chan uint32
 	LatestSerial   uint32
 	WindowGeometry XdgWindowGeometry
 }
 
-var GlobalEnterSerial uint32 = 0
-
-// Sends a configure event and waits for the client to ack it. Blocks until ack received.
-func (x *XdgSurface) configure(s protocols.ClientState) {
-	serial := x.LatestSerial
-	x.LatestSerial++
-
-	configureChannel := make(chan uint32, 1)
-	x.OnConfigure[serial] = configureChannel
-
-	protocols.XdgSurface_configure(s, x.XdgSurfaceID, serial)
-
-	<-configureChannel
-
-}
-
 /**
  * xdg_surface methods
  */
@@ -114,23 +96,50 @@ func (x *XdgSurface) XdgSurface_get_toplevel(
 
 	surfaceRole.Data = &id
 	AddObject(s, id, MakeXdgToplevel())
+	GetXdgToplevelObject(s, id).Raise()
 
 	RegisterRoleToSurface(s, id, *surface_id)
 	s.TopLevelSurfaces()[id] = true
 
-	protocols.XdgToplevel_configure(
+	/**
+	 * Both come before the first configure, clients
+	 * that bound an older xdg_wm_base don't get them.
+	 */
+	protocols.XdgToplevel_configure_bounds(
 		s,
 		id,
 		int32(VirtualMonitorSize.Width),
 		int32(VirtualMonitorSize.Height),
-		ToBytes([]protocols.XdgToplevelState_enum{
-			protocols.XdgToplevelState_enum_maximized,
-			protocols.XdgToplevelState_enum_fullscreen,
+	)
+	protocols.XdgToplevel_wm_capabilities(
+		s,
+		id,
+		ToBytes([]protocols.XdgToplevelWmCapabilities_enum{
+			protocols.XdgToplevelWmCapabilities_enum_window_menu,
+			protocols.XdgToplevelWmCapabilities_enum_maximize,
+			protocols.XdgToplevelWmCapabilities_enum_fullscreen,
+			protocols.XdgToplevelWmCapabilities_enum_minimize,
 		}),
 	)
 
-	// TODO this is necessary, but when?
-	protocols.XdgSurface_configure(s, xdgSurfaceObjectID, 0)
+	top := GetXdgToplevelObject(s, id)
+	if FloatingWindows {
+		/**
+		 * The client picks its size, and it
+		 * goes a little below the last window.
+		 */
+		top.X, top.Y = MoveResize.NextWindowPosition()
+	} else {
+		top.Maximized = true
+		top.Fullscreen = true
+	}
+	/**
+	 * New windows take focus, before the first
+	 * configure so it has the activated state.
+	 */
+	KeyboardFocus.Focus(s, *surface_id)
+	width, height, states := ToplevelConfiguration(s, id)
+	ConfigureToplevel(s, id, width, height, states...)
 
 	// TODO should this be here
 
@@ -139,56 +148,10 @@ func (x *XdgSurface) XdgSurface_get_toplevel(
 			protocols.WlSurface_enter(s, *surface_id, output_id)
 		}
 	}
-	serial := GlobalEnterSerial
-	GlobalEnterSerial += 1
-
-	if keyboard_binds := protocols.GetGlobalWlKeyboardBinds(s); keyboard_binds != nil {
-
-		for keyboard_id, _ := range keyboard_binds {
-			protocols.WlKeyboard_enter(s, keyboard_id, serial, *surface_id, []byte{})
-		}
-	}
-
-	if pointer_binds := protocols.GetGlobalWlPointerBinds(s); pointer_binds != nil {
-		for pointer_id, version := range pointer_binds {
-			protocols.WlPointer_enter(s, pointer_id, serial, *surface_id, Pointer.WindowX, Pointer.WindowY)
-			protocols.WlPointer_frame(s, uint32(version), pointer_id)
-		}
-	}
-
-	/**
-	 * commented because it
-	 * causes firefox to crash with:
-	 * "GraphicsCriticalError": "|[0][GFX1-]: (ubuntu:gnome) Wayland protocol error: listener function for opcode 2 of xdg_toplevel is NULL\n (t=0.503382) ",
-	 */
-	// xdg_toplevel_funcs.configure_bounds(
-	//   s,
-	//   this.version,
-	//   id,
-	//   virtual_monitor_size.width,
-	//   virtual_monitor_size.height
-	// );
 	/**
-	 * So with Xwayland clients, pointer enter doesn't stick?
-	 *
-	 * So let's do it again
-	 * after a timeout
-	 * @TODO where to actually put this?
+	 * The pointer enters once the surface has a texture
+	 * under it, see WlPointer.Refresh
 	 */
-
-	go func() {
-		time.Sleep(100 * time.Millisecond)
-		if pointer_binds := protocols.GetGlobalWlPointerBinds(s); pointer_binds != nil {
-			for pointer_id, _ := range pointer_binds {
-				pointer := GetWlPointerObject(s, pointer_id)
-				if pointer == nil {
-					continue
-				}
-				protocols.WlPointer_enter(s, pointer_id, 0, *surface_id, pointer.WindowX, pointer.WindowY)
-			}
-		}
-	}()
-
 }
 
 func (x *XdgSurface) XdgSurface_get_popup(
@@ -250,13 +213,7 @@ func (x *XdgSurface) XdgSurface_get_popup(
 
 	RegisterRoleToSurface(s, id, *surface_id)
 
-	protocols.XdgPopup_configure(
-		s,
-		id,
-		0, 0,
-		int32(VirtualMonitorSize.Width),
-		int32(VirtualMonitorSize.Height),
-	)
+	ConfigurePopup(s, id)
 }
 
 func (x *XdgSurface) XdgSurface_set_window_geometry(
@@ -284,24 +241,8 @@ func (x *XdgSurface) XdgSurface_set_window_geometry(
 func (x *XdgSurface) XdgSurface_ack_configure(
 	_ protocols.ClientState,
 	_ protocols.ObjectID[protocols.XdgSurface],
-	serial uint32,
+	_serial uint32,
 ) {
-	if x.OnConfigure == nil {
-		return
-	}
-	if cb, ok := x.OnConfigure[serial]; ok && cb != nil {
-		cb <- serial
-	}
-	for k := range x.OnConfigure {
-		if k > serial {
-			continue
-		}
-		//TODO do I need to close the channel?
-		if cb, ok := x.OnConfigure[k]; ok && cb != nil {
-			close(cb)
-		}
-		delete(x.OnConfigure, k)
-	}
 }
 
 func (x *XdgSurface) OnBind(
@@ -318,7 +259,6 @@ func MakeXdgSurface(version uint32, xdg_surface_id protocols.ObjectID[protocols.
 		Delegate: &XdgSurface{
 			Version:      version,
 			XdgSurfaceID: xdg_surface_id,
-			OnConfigure:  make(map[uint32]chan uint32),
 		},
 	}
 }
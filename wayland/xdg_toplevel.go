@@ -21,15 +21,57 @@ type XdgToplevel struct {
 	MinSize *Size
 	MaxSize *Size
 
+	/**
+	 * Where the window geometry starts on
+	 * the desktop, when FloatingWindows is on.
+	 */
+	X int32
+	Y int32
+
+	/**
+	 * Set from the window menu
+	 */
+	AlwaysOnTop bool
+
+	/**
+	 * Hidden until it is picked from the status line
+	 */
+	Minimized bool
+
+	/**
+	 * When it was last raised, see WindowStacking
+	 */
+	Raised uint64
+
 	PendingState *PendingToplevelState
 }
 
+/**
+ * Fit a size between MinSize and MaxSize
+ */
+func (t *XdgToplevel) ConstrainSize(width, height int32) (int32, int32) {
+	if t.MaxSize != nil {
+		width = min(width, int32(t.MaxSize.Width))
+		height = min(height, int32(t.MaxSize.Height))
+	}
+	if t.MinSize != nil {
+		width = max(width, int32(t.MinSize.Width))
+		height = max(height, int32(t.MinSize.Height))
+	}
+	return max(width, 1), max(height, 1)
+}
+
 func (t *XdgToplevel) XdgToplevel_destroy(
 	s protocols.ClientState,
 	objectID protocols.ObjectID[protocols.XdgToplevel],
 ) bool {
 	surface := GetSurfaceFromRole(s, objectID)
 
+	if surface_id := GetSurfaceIDFromRole(s, objectID); surface_id != nil {
+		KeyboardFocus.SurfaceUnmapped(s, *surface_id)
+	}
+	MoveResize.ToplevelDestroyed(s, objectID)
+	WindowMenu.ToplevelDestroyed(s, objectID)
 	UnregisterRoleToSurface(s, objectID)
 	s.TopLevelSurfaces()[objectID] = false
 	if surface != nil {
@@ -64,33 +106,36 @@ func (t *XdgToplevel) XdgToplevel_set_app_id(
 }
 
 func (t *XdgToplevel) XdgToplevel_show_window_menu(
-	_ protocols.ClientState,
-	_ protocols.ObjectID[protocols.XdgToplevel],
+	s protocols.ClientState,
+	objectID protocols.ObjectID[protocols.XdgToplevel],
 	_ protocols.ObjectID[protocols.WlSeat],
 	_ uint32, // serial
-	_ int32, // x
-	_ int32, // y
+	x int32,
+	y int32,
 ) {
-	// TODO: Implement show_window_menu
+	WindowMenu.Show(s, objectID, x, y)
 }
 
 func (t *XdgToplevel) XdgToplevel_move(
-	_ protocols.ClientState,
-	_ protocols.ObjectID[protocols.XdgToplevel],
+	s protocols.ClientState,
+	objectID protocols.ObjectID[protocols.XdgToplevel],
 	_ protocols.ObjectID[protocols.WlSeat],
 	_ uint32, // serial
 ) {
-	// TODO: Implement move
+	MoveResize.Begin(s, objectID, protocols.XdgToplevelResizeEdge_enum_none)
 }
 
 func (t *XdgToplevel) XdgToplevel_resize(
-	_ protocols.ClientState,
-	_ protocols.ObjectID[protocols.XdgToplevel],
+	s protocols.ClientState,
+	objectID protocols.ObjectID[protocols.XdgToplevel],
 	_ protocols.ObjectID[protocols.WlSeat],
 	_ uint32, // serial
-	_ protocols.XdgToplevelResizeEdge_enum, // edges
+	edges protocols.XdgToplevelResizeEdge_enum,
 ) {
-	// TODO: Implement resize
+	if edges == protocols.XdgToplevelResizeEdge_enum_none {
+		return
+	}
+	MoveResize.Begin(s, objectID, edges)
 }
 
 func (t *XdgToplevel) XdgToplevel_set_max_size(
@@ -138,23 +183,14 @@ func (t *XdgToplevel) XdgToplevel_set_maximized(
 	s protocols.ClientState,
 	objectID protocols.ObjectID[protocols.XdgToplevel],
 ) {
-	go func() {
-		if shouldChange := t.stateConfiguration(s, objectID, true, t.Fullscreen); shouldChange {
-			t.Maximized = true
-		}
-	}()
-
+	t.stateConfiguration(s, objectID, true, t.Fullscreen)
 }
 
 func (t *XdgToplevel) XdgToplevel_unset_maximized(
 	s protocols.ClientState,
 	objectID protocols.ObjectID[protocols.XdgToplevel],
 ) {
-	go func() {
-		if shouldChange := t.stateConfiguration(s, objectID, false, t.Fullscreen); shouldChange {
-			t.Maximized = false
-		}
-	}()
+	t.stateConfiguration(s, objectID, false, t.Fullscreen)
 }
 
 func (t *XdgToplevel) XdgToplevel_set_fullscreen(
@@ -162,29 +198,42 @@ func (t *XdgToplevel) XdgToplevel_set_fullscreen(
 	objectID protocols.ObjectID[protocols.XdgToplevel],
 	_ *protocols.ObjectID[protocols.WlOutput],
 ) {
-	go func() {
-		if shouldChange := t.stateConfiguration(s, objectID, t.Maximized, true); shouldChange {
-			t.Fullscreen = true
-		}
-	}()
+	t.stateConfiguration(s, objectID, t.Maximized, true)
 }
 
 func (t *XdgToplevel) XdgToplevel_unset_fullscreen(
 	s protocols.ClientState,
 	objectID protocols.ObjectID[protocols.XdgToplevel],
 ) {
-	go func() {
-		if shouldChange := t.stateConfiguration(s, objectID, t.Maximized, false); shouldChange {
-			t.Fullscreen = false
-		}
-	}()
+	t.stateConfiguration(s, objectID, t.Maximized, false)
 }
 
 func (t *XdgToplevel) XdgToplevel_set_minimized(
-	_ protocols.ClientState,
-	_ protocols.ObjectID[protocols.XdgToplevel],
+	s protocols.ClientState,
+	objectID protocols.ObjectID[protocols.XdgToplevel],
 ) {
-	// TODO: Implement minimize behavior if desired
+	t.Minimized = true
+	/**
+	 * Focus goes back to the window before it
+	 */
+	if surface_id := GetSurfaceIDFromRole(s, objectID); surface_id != nil {
+		KeyboardFocus.SurfaceUnmapped(s, *surface_id)
+	}
+}
+
+/**
+ * Show a minimized window again, above the others, with
+ * focus. Returns false if a keyboard grab kept focus
+ * elsewhere. s must be locked.
+ */
+func (t *XdgToplevel) Restore(
+	s protocols.ClientState,
+	objectID protocols.ObjectID[protocols.XdgToplevel],
+) bool {
+	t.Minimized = false
+	t.Raise()
+	surface_id := GetSurfaceIDFromRole(s, objectID)
+	return surface_id != nil && KeyboardFocus.Focus(s, *surface_id)
 }
 
 func (t *XdgToplevel) OnBind(
@@ -197,31 +246,19 @@ func (t *XdgToplevel) OnBind(
 	// No-op
 }
 
+/**
+ * Set the maximized and fullscreen states and send the
+ * configure for them, the client commits the new size
+ * in its own time. s must be locked.
+ */
 func (t *XdgToplevel) stateConfiguration(
 	s protocols.ClientState,
 	objectID protocols.ObjectID[protocols.XdgToplevel],
 	maximized bool,
 	fullscreen bool,
-) bool {
-	// const data = s.get_role_data_from_role(object_id, "xdg_toplevel");
-	// if (!data) {
-	//   return false;
-	// }
-
-	// Ensure the role resolves to a wl_surface
-	surface := GetSurfaceFromRole(s, objectID)
-	if surface == nil {
-		return false
-	}
-	if surface.XdgSurfaceState == nil {
-		return false
-	}
-
-	xdg_surface_State := GetXdgSurfaceObject(s, *surface.XdgSurfaceState)
-
-	if xdg_surface_State == nil {
-		return false
-	}
+) {
+	t.Maximized = maximized
+	t.Fullscreen = fullscreen
 
 	var states []protocols.XdgToplevelState_enum
 	if maximized {
@@ -231,16 +268,14 @@ func (t *XdgToplevel) stateConfiguration(
 		states = append(states, protocols.XdgToplevelState_enum_fullscreen)
 	}
 
-	protocols.XdgToplevel_configure(
-		s,
-		objectID,
-		int32(VirtualMonitorSize.Width),
-		int32(VirtualMonitorSize.Height),
-		ToBytes(states),
-	)
-	xdg_surface_State.configure(s)
-
-	return true
+	width, height := int32(VirtualMonitorSize.Width), int32(VirtualMonitorSize.Height)
+	if FloatingWindows && !maximized && !fullscreen {
+		/**
+		 * Back to floating, the client picks its size
+		 */
+		width, height = 0, 0
+	}
+	ConfigureToplevel(s, objectID, width, height, states...)
 }
 
 func MakeXdgToplevel() *protocols.XdgToplevel {
@@ -23,6 +23,10 @@ func (x *XwaylandShellV1) XwaylandShellV1_get_xwayland_surface(
 	id protocols.ObjectID[protocols.XwaylandSurfaceV1],
 	surface_id protocols.ObjectID[protocols.WlSurface],
 ) {
+	if !s.IsXwayland() {
+		SendError(s, object_id, protocols.WlDisplayError_enum_invalid_object, "only Xwayland can use xwayland_shell_v1")
+		return
+	}
 	surface := GetWlSurfaceObject(s, surface_id)
 	if surface == nil {
 		fmt.Printf("xwayland_shell_v1_get_xwayland_surface: surface not found\n")
@@ -61,7 +65,9 @@ func (x *XwaylandShellV1) XwaylandShellV1_get_xwayland_surface(
 		)
 		return
 	}
+	surfaceRole.Data = &SurfaceRoleWaylandSurfaceData{}
 	AddObject(s, id, MakeXwaylandSurfaceV1())
+	RegisterRoleToSurface(s, id, surface_id)
 }
 
 func (x *XwaylandShellV1) OnBind(
@@ -10,12 +10,44 @@ type XwaylandSurfaceV1 struct {
 }
 
 func (x *XwaylandSurfaceV1) XwaylandSurfaceV1_set_serial(
-	_s protocols.ClientState,
-	_object_id protocols.ObjectID[protocols.XwaylandSurfaceV1],
-	_serial_lo uint32,
-	_serial_hi uint32,
+	s protocols.ClientState,
+	object_id protocols.ObjectID[protocols.XwaylandSurfaceV1],
+	serial_lo uint32,
+	serial_hi uint32,
 ) {
-	/** @TODO: Implement XwaylandSurfaceV1_set_serial */
+	surface := GetSurfaceFromRole(s, object_id)
+	if surface == nil {
+		return
+	}
+	role, ok := surface.Role.(*SurfaceRoleXWaylandSurface)
+	if !ok || role.Data == nil {
+		return
+	}
+	if role.Data.Serial != nil || surface.PendingUpdate.XwaylandSurfarfaceV1Serial != nil {
+		SendError(
+			s,
+			object_id,
+			protocols.XwaylandSurfaceV1Error_enum_already_associated,
+			"surface already has a serial",
+		)
+		return
+	}
+	if serial_lo == 0 && serial_hi == 0 {
+		SendError(
+			s,
+			object_id,
+			protocols.XwaylandSurfaceV1Error_enum_invalid_serial,
+			"serial 0 is not valid",
+		)
+		return
+	}
+	/**
+	 * Double buffered, paired with its X window on commit
+	 */
+	surface.PendingUpdate.XwaylandSurfarfaceV1Serial = &XWaylandSurfaceV1Serial{
+		Low: serial_lo,
+		Hi:  serial_hi,
+	}
 }
 
 func (x *XwaylandSurfaceV1) XwaylandSurfaceV1_destroy(
@@ -28,6 +60,12 @@ func (x *XwaylandSurfaceV1) XwaylandSurfaceV1_destroy(
 		fmt.Printf("XwaylandSurfaceV1_destroy: surface not found")
 		return true
 	}
+	if surface_id := GetSurfaceIDFromRole(s, object_id); surface_id != nil {
+		KeyboardFocus.SurfaceUnmapped(s, *surface_id)
+		XWindows.SurfaceDestroyed(s, *surface_id)
+		delete(s.DrawableSurfaces(), *surface_id)
+	}
+	UnregisterRoleToSurface(s, object_id)
 	if role, ok := surface.Role.(*SurfaceRoleXWaylandSurface); ok {
 		role.Data = nil
 	}
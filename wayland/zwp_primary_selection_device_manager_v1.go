@@ -0,0 +1,49 @@
+package wayland
+
+import (
+	"github.com/mmulet/term.everything/wayland/protocols"
+)
+
+type ZwpPrimarySelectionDeviceManagerV1 struct{}
+
+func (z *ZwpPrimarySelectionDeviceManagerV1) ZwpPrimarySelectionDeviceManagerV1_create_source(
+	s protocols.ClientState,
+	_ protocols.ObjectID[protocols.ZwpPrimarySelectionDeviceManagerV1],
+	id protocols.ObjectID[protocols.ZwpPrimarySelectionSourceV1],
+) {
+	s.AddObject(protocols.AnyObjectID(id), MakeZwpPrimarySelectionSourceV1(s, id))
+}
+
+func (z *ZwpPrimarySelectionDeviceManagerV1) ZwpPrimarySelectionDeviceManagerV1_get_device(
+	s protocols.ClientState,
+	_ protocols.ObjectID[protocols.ZwpPrimarySelectionDeviceManagerV1],
+	id protocols.ObjectID[protocols.ZwpPrimarySelectionDeviceV1],
+	seat protocols.ObjectID[protocols.WlSeat],
+) {
+	device := MakeZwpPrimarySelectionDeviceV1(seat, id)
+	s.AddObject(protocols.AnyObjectID(id), device)
+	PrimarySelection.AddDevice(s, protocols.AnyObjectID(id), device.Delegate.(*ZwpPrimarySelectionDeviceV1))
+}
+
+func (z *ZwpPrimarySelectionDeviceManagerV1) ZwpPrimarySelectionDeviceManagerV1_destroy(
+	_ protocols.ClientState,
+	_ protocols.ObjectID[protocols.ZwpPrimarySelectionDeviceManagerV1],
+) bool {
+	return true
+}
+
+func (z *ZwpPrimarySelectionDeviceManagerV1) OnBind(
+	_ protocols.ClientState,
+	_ protocols.AnyObjectID,
+	_ string,
+	_ protocols.AnyObjectID,
+	_ uint32,
+) {
+	// no-op
+}
+
+func MakeZwpPrimarySelectionDeviceManagerV1() *protocols.ZwpPrimarySelectionDeviceManagerV1 {
+	return &protocols.ZwpPrimarySelectionDeviceManagerV1{
+		Delegate: &ZwpPrimarySelectionDeviceManagerV1{},
+	}
+}
@@ -0,0 +1,68 @@
+package wayland
+
+import (
+	"github.com/mmulet/term.everything/wayland/protocols"
+)
+
+type ZwpPrimarySelectionDeviceV1 struct {
+	Seat protocols.ObjectID[protocols.WlSeat]
+	ID   protocols.ObjectID[protocols.ZwpPrimarySelectionDeviceV1]
+}
+
+func (z *ZwpPrimarySelectionDeviceV1) ZwpPrimarySelectionDeviceV1_set_selection(
+	s protocols.ClientState,
+	_ protocols.ObjectID[protocols.ZwpPrimarySelectionDeviceV1],
+	source *protocols.ObjectID[protocols.ZwpPrimarySelectionSourceV1],
+	_ uint32,
+) {
+	if source == nil {
+		PrimarySelection.SetSelection(nil)
+		return
+	}
+	selectionSource := GetZwpPrimarySelectionSourceV1Object(s, *source)
+	if selectionSource == nil {
+		return
+	}
+	PrimarySelection.SetSelection(selectionSource)
+}
+
+func (z *ZwpPrimarySelectionDeviceV1) ZwpPrimarySelectionDeviceV1_destroy(
+	s protocols.ClientState,
+	object_id protocols.ObjectID[protocols.ZwpPrimarySelectionDeviceV1],
+) bool {
+	PrimarySelection.RemoveDevice(s, protocols.AnyObjectID(object_id))
+	return true
+}
+
+func (z *ZwpPrimarySelectionDeviceV1) OnBind(
+	_ protocols.ClientState,
+	_ protocols.AnyObjectID,
+	_ string,
+	_ protocols.AnyObjectID,
+	_ uint32,
+) {
+}
+
+/**
+ * Create a new zwp_primary_selection_offer_v1 for
+ * source and announce it as the selection.
+ */
+func (z *ZwpPrimarySelectionDeviceV1) OfferSelection(s protocols.ClientState, source SelectionSource) {
+	if source == nil {
+		protocols.ZwpPrimarySelectionDeviceV1_selection(s, z.ID, nil)
+		return
+	}
+	offerID := protocols.ObjectID[protocols.ZwpPrimarySelectionOfferV1](s.NewServerObjectID())
+	s.AddObject(protocols.AnyObjectID(offerID), MakeZwpPrimarySelectionOfferV1(source))
+	protocols.ZwpPrimarySelectionDeviceV1_data_offer(s, z.ID, offerID)
+	for _, mimeType := range source.GetMimeTypes() {
+		protocols.ZwpPrimarySelectionOfferV1_offer(s, offerID, mimeType)
+	}
+	protocols.ZwpPrimarySelectionDeviceV1_selection(s, z.ID, &offerID)
+}
+
+func MakeZwpPrimarySelectionDeviceV1(seat protocols.ObjectID[protocols.WlSeat], id protocols.ObjectID[protocols.ZwpPrimarySelectionDeviceV1]) *protocols.ZwpPrimarySelectionDeviceV1 {
+	return &protocols.ZwpPrimarySelectionDeviceV1{
+		Delegate: &ZwpPrimarySelectionDeviceV1{Seat: seat, ID: id},
+	}
+}
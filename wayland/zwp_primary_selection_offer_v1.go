@@ -0,0 +1,50 @@
+package wayland
+
+import (
+	"slices"
+	"syscall"
+
+	"github.com/mmulet/term.everything/wayland/protocols"
+)
+
+type ZwpPrimarySelectionOfferV1 struct {
+	Source SelectionSource
+}
+
+func (z *ZwpPrimarySelectionOfferV1) ZwpPrimarySelectionOfferV1_receive(
+	_ protocols.ClientState,
+	_ protocols.ObjectID[protocols.ZwpPrimarySelectionOfferV1],
+	mime_type string,
+	fd *protocols.FileDescriptor,
+) {
+	if fd == nil {
+		return
+	}
+	if z.Source == nil || !slices.Contains(z.Source.GetMimeTypes(), mime_type) {
+		syscall.Close(int(*fd))
+		return
+	}
+	z.Source.SendTo(mime_type, *fd)
+}
+
+func (z *ZwpPrimarySelectionOfferV1) ZwpPrimarySelectionOfferV1_destroy(
+	_ protocols.ClientState,
+	_ protocols.ObjectID[protocols.ZwpPrimarySelectionOfferV1],
+) bool {
+	return true
+}
+
+func (z *ZwpPrimarySelectionOfferV1) OnBind(
+	_ protocols.ClientState,
+	_ protocols.AnyObjectID,
+	_ string,
+	_ protocols.AnyObjectID,
+	_ uint32,
+) {
+}
+
+func MakeZwpPrimarySelectionOfferV1(source SelectionSource) *protocols.ZwpPrimarySelectionOfferV1 {
+	return &protocols.ZwpPrimarySelectionOfferV1{
+		Delegate: &ZwpPrimarySelectionOfferV1{Source: source},
+	}
+}
@@ -0,0 +1,94 @@
+package wayland
+
+import (
+	"slices"
+	"sync"
+	"syscall"
+
+	"github.com/mmulet/term.everything/wayland/protocols"
+)
+
+type ZwpPrimarySelectionSourceV1 struct {
+	Client protocols.ClientState
+	ID     protocols.ObjectID[protocols.ZwpPrimarySelectionSourceV1]
+
+	/**
+	 * Guards MimeTypes and Destroyed, see WlDataSource
+	 */
+	Access sync.Mutex
+
+	MimeTypes []string
+
+	Destroyed bool
+}
+
+func (z *ZwpPrimarySelectionSourceV1) ZwpPrimarySelectionSourceV1_offer(
+	_ protocols.ClientState,
+	_ protocols.ObjectID[protocols.ZwpPrimarySelectionSourceV1],
+	mime_type string,
+) {
+	z.Access.Lock()
+	defer z.Access.Unlock()
+	z.MimeTypes = append(z.MimeTypes, mime_type)
+}
+
+func (z *ZwpPrimarySelectionSourceV1) ZwpPrimarySelectionSourceV1_destroy(
+	_ protocols.ClientState,
+	_ protocols.ObjectID[protocols.ZwpPrimarySelectionSourceV1],
+) bool {
+	z.Access.Lock()
+	z.Destroyed = true
+	z.Access.Unlock()
+	PrimarySelection.SourceDestroyed(z)
+	return true
+}
+
+func (z *ZwpPrimarySelectionSourceV1) OnBind(
+	_ protocols.ClientState,
+	_ protocols.AnyObjectID,
+	_ string,
+	_ protocols.AnyObjectID,
+	_ uint32,
+) {
+}
+
+func (z *ZwpPrimarySelectionSourceV1) GetMimeTypes() []string {
+	z.Access.Lock()
+	defer z.Access.Unlock()
+	return slices.Clone(z.MimeTypes)
+}
+
+func (z *ZwpPrimarySelectionSourceV1) IsDestroyed() bool {
+	z.Access.Lock()
+	defer z.Access.Unlock()
+	return z.Destroyed
+}
+
+func (z *ZwpPrimarySelectionSourceV1) SendTo(mime_type string, fd protocols.FileDescriptor) {
+	if z.IsDestroyed() || !slices.Contains(z.GetMimeTypes(), mime_type) {
+		syscall.Close(int(fd))
+		return
+	}
+	protocols.ZwpPrimarySelectionSourceV1_send(closeFileDescriptorAfterSend{z.Client}, z.ID, mime_type, fd)
+}
+
+func (z *ZwpPrimarySelectionSourceV1) Cancel() {
+	if z.IsDestroyed() {
+		return
+	}
+	protocols.ZwpPrimarySelectionSourceV1_cancelled(z.Client, z.ID)
+}
+
+func (z *ZwpPrimarySelectionSourceV1) Owner() protocols.ClientState {
+	return z.Client
+}
+
+func MakeZwpPrimarySelectionSourceV1(s protocols.ClientState, id protocols.ObjectID[protocols.ZwpPrimarySelectionSourceV1]) *protocols.ZwpPrimarySelectionSourceV1 {
+	return &protocols.ZwpPrimarySelectionSourceV1{
+		Delegate: &ZwpPrimarySelectionSourceV1{
+			Client:    s,
+			ID:        id,
+			MimeTypes: []string{},
+		},
+	}
+}
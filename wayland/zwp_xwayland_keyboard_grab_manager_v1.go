@@ -17,15 +17,28 @@ func (m *ZwpXwaylandKeyboardGrabManagerV1) ZwpXwaylandKeyboardGrabManagerV1_dest
 
 func (m *ZwpXwaylandKeyboardGrabManagerV1) ZwpXwaylandKeyboardGrabManagerV1_grab_keyboard(
 	s protocols.ClientState,
-	_object_id protocols.ObjectID[protocols.ZwpXwaylandKeyboardGrabManagerV1],
+	object_id protocols.ObjectID[protocols.ZwpXwaylandKeyboardGrabManagerV1],
 	id protocols.ObjectID[protocols.ZwpXwaylandKeyboardGrabV1],
-	_surface protocols.ObjectID[protocols.WlSurface],
+	surface protocols.ObjectID[protocols.WlSurface],
 	_seat protocols.ObjectID[protocols.WlSeat],
 ) {
-	AddObject(s, id, MakeZwpXwaylandKeyboardGrabV1())
 	/**
-	 * @TODO grab the keyboard
+	 * The global is only advertised to Xwayland,
+	 * a grab takes every key from every other client.
 	 */
+	if !s.IsXwayland() {
+		SendError(s, object_id, protocols.WlDisplayError_enum_invalid_object, "only Xwayland can grab the keyboard")
+		return
+	}
+	AddObject(s, id, MakeZwpXwaylandKeyboardGrabV1(surface))
+	if GetWlSurfaceObject(s, surface) == nil {
+		/**
+		 * The grab object still has to exist
+		 * so it can be destroyed.
+		 */
+		return
+	}
+	KeyboardFocus.StartGrab(s, surface)
 }
 
 func (m *ZwpXwaylandKeyboardGrabManagerV1) OnBind(
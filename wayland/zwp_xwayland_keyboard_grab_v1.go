@@ -5,15 +5,14 @@ import (
 )
 
 type ZwpXwaylandKeyboardGrabV1 struct {
+	Surface protocols.ObjectID[protocols.WlSurface]
 }
 
 func (g *ZwpXwaylandKeyboardGrabV1) ZwpXwaylandKeyboardGrabV1_destroy(
-	_ protocols.ClientState,
+	s protocols.ClientState,
 	_ protocols.ObjectID[protocols.ZwpXwaylandKeyboardGrabV1],
 ) bool {
-	/**
-	 * @TODO ungrab the keyboard
-	 */
+	KeyboardFocus.EndGrab(s, g.Surface)
 	return true
 }
 
@@ -26,8 +25,8 @@ func (g *ZwpXwaylandKeyboardGrabV1) OnBind(
 ) {
 }
 
-func MakeZwpXwaylandKeyboardGrabV1() *protocols.ZwpXwaylandKeyboardGrabV1 {
+func MakeZwpXwaylandKeyboardGrabV1(surface protocols.ObjectID[protocols.WlSurface]) *protocols.ZwpXwaylandKeyboardGrabV1 {
 	return &protocols.ZwpXwaylandKeyboardGrabV1{
-		Delegate: &ZwpXwaylandKeyboardGrabV1{},
+		Delegate: &ZwpXwaylandKeyboardGrabV1{Surface: surface},
 	}
 }
@@ -0,0 +1,452 @@
+package xwm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/**
+ * Just enough of the X11 protocol for a window manager.
+ * Requests can be sent from any goroutine, but only one
+ * goroutine may read (wait for replies and events).
+ */
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	writeAccess sync.Mutex
+	/**
+	 * Sequence number of the last request sent
+	 */
+	sequence uint16
+
+	Root       uint32
+	RootWidth  uint16
+	RootHeight uint16
+
+	/**
+	 * Events read while waiting for a reply
+	 */
+	pending [][]byte
+}
+
+/**
+ * An X error, the reply to a request that failed
+ */
+type XError struct {
+	Code     byte
+	Sequence uint16
+	BadValue uint32
+	Major    byte
+}
+
+func (e *XError) Error() string {
+	return fmt.Sprintf("X error %d for request %d (value %d)", e.Code, e.Major, e.BadValue)
+}
+
+/**
+ * Length of the fixed part of replies, events and errors
+ */
+const MessageSize = 32
+
+const (
+	messageError        = 0
+	messageReply        = 1
+	messageGenericEvent = 35
+)
+
+var order = binary.LittleEndian
+
+/**
+ * Connect to a local display, like ":5"
+ */
+func Dial(display string) (*Conn, error) {
+	number, ok := strings.CutPrefix(display, ":")
+	if !ok {
+		return nil, fmt.Errorf("only local displays are supported, not %q", display)
+	}
+	number, _, _ = strings.Cut(number, ".")
+	if _, err := strconv.Atoi(number); err != nil {
+		return nil, fmt.Errorf("bad display %q", display)
+	}
+	path := "/tmp/.X11-unix/X" + number
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		/**
+		 * Try the abstract socket Xwayland also listens on
+		 */
+		var abstractErr error
+		conn, abstractErr = net.Dial("unix", "@"+path)
+		if abstractErr != nil {
+			return nil, fmt.Errorf("failed to connect to X display %s: %w", display, err)
+		}
+	}
+	c := &Conn{conn: conn, reader: bufio.NewReader(conn)}
+	if err := c.setup(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+func pad(n int) int {
+	return (4 - n%4) % 4
+}
+
+/**
+ * Send the connection setup (no authorization, we started
+ * the server ourselves) and read the first screen.
+ */
+func (c *Conn) setup() error {
+	request := make([]byte, 12)
+	request[0] = 'l'
+	order.PutUint16(request[2:], 11)
+	order.PutUint16(request[4:], 0)
+	if _, err := c.conn.Write(request); err != nil {
+		return err
+	}
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return fmt.Errorf("failed to read X setup: %w", err)
+	}
+	body := make([]byte, int(order.Uint16(header[6:]))*4)
+	if _, err := io.ReadFull(c.reader, body); err != nil {
+		return fmt.Errorf("failed to read X setup: %w", err)
+	}
+	if header[0] != 1 {
+		reason := body[:min(int(header[1]), len(body))]
+		return fmt.Errorf("X server refused the connection: %s", reason)
+	}
+
+	/**
+	 * Vendor and pixmap formats come before the screens
+	 */
+	if len(body) < 32 {
+		return fmt.Errorf("X setup reply too short")
+	}
+	vendorLength := int(order.Uint16(body[16:]))
+	numFormats := int(body[21])
+	screen := 32 + vendorLength + pad(vendorLength) + numFormats*8
+	if len(body) < screen+40 {
+		return fmt.Errorf("X setup reply has no screens")
+	}
+	c.Root = order.Uint32(body[screen:])
+	c.RootWidth = order.Uint16(body[screen+20:])
+	c.RootHeight = order.Uint16(body[screen+22:])
+	return nil
+}
+
+/**
+ * Send a request, returns its sequence number
+ */
+func (c *Conn) send(request []byte) (uint16, error) {
+	c.writeAccess.Lock()
+	defer c.writeAccess.Unlock()
+	if _, err := c.conn.Write(request); err != nil {
+		return 0, err
+	}
+	c.sequence++
+	return c.sequence, nil
+}
+
+/**
+ * Read one reply, event or error
+ */
+func (c *Conn) read() ([]byte, error) {
+	message := make([]byte, MessageSize)
+	if _, err := io.ReadFull(c.reader, message); err != nil {
+		return nil, err
+	}
+	if message[0] == messageReply || message[0]&0x7f == messageGenericEvent {
+		extra := make([]byte, int(order.Uint32(message[4:]))*4)
+		if _, err := io.ReadFull(c.reader, extra); err != nil {
+			return nil, err
+		}
+		message = append(message, extra...)
+	}
+	return message, nil
+}
+
+func toXError(message []byte) *XError {
+	return &XError{
+		Code:     message[1],
+		Sequence: order.Uint16(message[2:]),
+		BadValue: order.Uint32(message[4:]),
+		Major:    message[10],
+	}
+}
+
+/**
+ * Send a request that has a reply, and wait for it.
+ * Events that arrive first are kept for NextEvent.
+ */
+func (c *Conn) roundTrip(request []byte) ([]byte, error) {
+	sequence, err := c.send(request)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		message, err := c.read()
+		if err != nil {
+			return nil, err
+		}
+		switch message[0] {
+		case messageReply:
+			if order.Uint16(message[2:]) == sequence {
+				return message, nil
+			}
+		case messageError:
+			if order.Uint16(message[2:]) == sequence {
+				return nil, toXError(message)
+			}
+		default:
+			c.pending = append(c.pending, message)
+		}
+	}
+}
+
+/**
+ * Send a request without a reply, and wait
+ * to find out if it failed.
+ */
+func (c *Conn) checked(request []byte) error {
+	sequence, err := c.send(request)
+	if err != nil {
+		return err
+	}
+	/**
+	 * Requests are handled in order, once the reply
+	 * to this one arrives any error would have too.
+	 */
+	syncSequence, err := c.send(getInputFocusRequest())
+	if err != nil {
+		return err
+	}
+	var requestErr error
+	for {
+		message, err := c.read()
+		if err != nil {
+			return err
+		}
+		switch message[0] {
+		case messageReply:
+			if order.Uint16(message[2:]) == syncSequence {
+				return requestErr
+			}
+		case messageError:
+			if order.Uint16(message[2:]) == sequence {
+				requestErr = toXError(message)
+			}
+		default:
+			c.pending = append(c.pending, message)
+		}
+	}
+}
+
+/**
+ * Wait for the next event. Errors from requests
+ * nobody waited on are dropped.
+ */
+func (c *Conn) NextEvent() ([]byte, error) {
+	if len(c.pending) > 0 {
+		event := c.pending[0]
+		c.pending = c.pending[1:]
+		return event, nil
+	}
+	for {
+		message, err := c.read()
+		if err != nil {
+			return nil, err
+		}
+		if message[0] == messageError || message[0] == messageReply {
+			continue
+		}
+		return message, nil
+	}
+}
+
+/**
+ * opcode, a data byte, then the length
+ * in 4 byte units, filled in by finish.
+ */
+func newRequest(opcode byte, data byte) []byte {
+	return []byte{opcode, data, 0, 0}
+}
+
+func putUint32(request []byte, values ...uint32) []byte {
+	for _, v := range values {
+		request = order.AppendUint32(request, v)
+	}
+	return request
+}
+
+func finish(request []byte) []byte {
+	request = append(request, make([]byte, pad(len(request)))...)
+	order.PutUint16(request[2:], uint16(len(request)/4))
+	return request
+}
+
+const (
+	opChangeWindowAttributes = 2
+	opMapWindow              = 8
+	opConfigureWindow        = 12
+	opInternAtom             = 16
+	opGetProperty            = 20
+	opSetInputFocus          = 42
+	opGetInputFocus          = 43
+)
+
+func getInputFocusRequest() []byte {
+	return finish(newRequest(opGetInputFocus, 0))
+}
+
+func (c *Conn) InternAtom(name string) (uint32, error) {
+	request := newRequest(opInternAtom, 0)
+	request = order.AppendUint16(request, uint16(len(name)))
+	request = append(request, 0, 0)
+	request = append(request, name...)
+	reply, err := c.roundTrip(finish(request))
+	if err != nil {
+		return 0, err
+	}
+	return order.Uint32(reply[8:]), nil
+}
+
+/**
+ * Window attribute mask bits
+ */
+const CWEventMask = 1 << 11
+
+/**
+ * Event mask bits
+ */
+const (
+	PropertyChangeMask       = 1 << 22
+	SubstructureNotifyMask   = 1 << 19
+	SubstructureRedirectMask = 1 << 20
+)
+
+/**
+ * values are in mask bit order
+ */
+func changeWindowAttributesRequest(window uint32, mask uint32, values ...uint32) []byte {
+	request := newRequest(opChangeWindowAttributes, 0)
+	request = putUint32(request, window, mask)
+	request = putUint32(request, values...)
+	return finish(request)
+}
+
+func (c *Conn) SelectInput(window uint32, eventMask uint32) error {
+	_, err := c.send(changeWindowAttributesRequest(window, CWEventMask, eventMask))
+	return err
+}
+
+/**
+ * SelectInput, and wait to see if it worked. Only one
+ * client can select substructure redirect on a window.
+ */
+func (c *Conn) SelectInputChecked(window uint32, eventMask uint32) error {
+	return c.checked(changeWindowAttributesRequest(window, CWEventMask, eventMask))
+}
+
+func (c *Conn) MapWindow(window uint32) error {
+	request := newRequest(opMapWindow, 0)
+	request = putUint32(request, window)
+	_, err := c.send(finish(request))
+	return err
+}
+
+/**
+ * ConfigureWindow value mask bits
+ */
+const (
+	ConfigWindowX           = 1 << 0
+	ConfigWindowY           = 1 << 1
+	ConfigWindowWidth       = 1 << 2
+	ConfigWindowHeight      = 1 << 3
+	ConfigWindowBorderWidth = 1 << 4
+	ConfigWindowSibling     = 1 << 5
+	ConfigWindowStackMode   = 1 << 6
+)
+
+const StackModeAbove = 0
+
+/**
+ * values are in mask bit order
+ */
+func (c *Conn) ConfigureWindow(window uint32, mask uint16, values ...uint32) error {
+	request := newRequest(opConfigureWindow, 0)
+	request = putUint32(request, window)
+	request = order.AppendUint16(request, mask)
+	request = append(request, 0, 0)
+	request = putUint32(request, values...)
+	_, err := c.send(finish(request))
+	return err
+}
+
+const RevertToPointerRoot = 1
+
+func (c *Conn) SetInputFocus(window uint32) error {
+	request := newRequest(opSetInputFocus, RevertToPointerRoot)
+	/**
+	 * 0 is CurrentTime
+	 */
+	request = putUint32(request, window, 0)
+	_, err := c.send(finish(request))
+	return err
+}
+
+/**
+ * AnyPropertyType for GetProperty
+ */
+const AnyPropertyType = 0
+
+/**
+ * Predefined atoms
+ */
+const (
+	AtomString = 31
+	AtomWMName = 39
+)
+
+/**
+ * Properties longer than this (in 4 byte units) are cut off
+ */
+const MaxPropertyLength = 1024
+
+type Property struct {
+	Type   uint32
+	Format byte
+	Value  []byte
+}
+
+func (c *Conn) GetProperty(window uint32, property uint32, propertyType uint32) (*Property, error) {
+	request := newRequest(opGetProperty, 0)
+	request = putUint32(request, window, property, propertyType, 0, MaxPropertyLength)
+	reply, err := c.roundTrip(finish(request))
+	if err != nil {
+		return nil, err
+	}
+	format := reply[1]
+	length := int(order.Uint32(reply[16:])) * int(format/8)
+	value := reply[MessageSize:]
+	if length > len(value) {
+		return nil, fmt.Errorf("GetProperty reply too short")
+	}
+	return &Property{
+		Type:   order.Uint32(reply[8:]),
+		Format: format,
+		Value:  value[:length],
+	}, nil
+}
@@ -0,0 +1,205 @@
+package xwm
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mmulet/term.everything/wayland"
+)
+
+/**
+ * A minimal X window manager for Xwayland. It maps what
+ * asks to be mapped, lets windows place themselves, and
+ * tells the compositor about them (position, title and the
+ * serial pairing them with their xwayland surface). The
+ * compositor does the rest, like it does for xdg toplevels.
+ */
+type WindowManager struct {
+	Conn *Conn
+
+	WlSurfaceSerial uint32
+	NetWMName       uint32
+	UTF8String      uint32
+}
+
+/**
+ * Event codes
+ */
+const (
+	CreateNotify     = 16
+	DestroyNotify    = 17
+	MapRequest       = 20
+	ConfigureNotify  = 22
+	ConfigureRequest = 23
+	PropertyNotify   = 28
+	ClientMessage    = 33
+)
+
+/**
+ * Connect to display and become its window manager
+ */
+func Start(display string) (*WindowManager, error) {
+	conn, err := Dial(display)
+	if err != nil {
+		return nil, err
+	}
+	wm := &WindowManager{Conn: conn}
+	for name, atom := range map[string]*uint32{
+		"WL_SURFACE_SERIAL": &wm.WlSurfaceSerial,
+		"_NET_WM_NAME":      &wm.NetWMName,
+		"UTF8_STRING":       &wm.UTF8String,
+	} {
+		if *atom, err = conn.InternAtom(name); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to intern %s: %w", name, err)
+		}
+	}
+	if err := conn.SelectInputChecked(conn.Root, SubstructureRedirectMask|SubstructureNotifyMask); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("another window manager is running on %s: %w", display, err)
+	}
+	wayland.XWindows.SetManager(wm)
+	go wm.Run()
+	return wm, nil
+}
+
+func (wm *WindowManager) Close() {
+	wm.Conn.Close()
+}
+
+/**
+ * Handle events until the connection closes
+ */
+func (wm *WindowManager) Run() {
+	defer wayland.XWindows.RemoveManager(wm)
+	for {
+		event, err := wm.Conn.NextEvent()
+		if err != nil {
+			return
+		}
+		wm.HandleEvent(event)
+	}
+}
+
+func (wm *WindowManager) HandleEvent(event []byte) {
+	root := wm.Conn.Root
+	switch event[0] & 0x7f {
+	case CreateNotify:
+		if order.Uint32(event[4:]) != root {
+			return
+		}
+		window := order.Uint32(event[8:])
+		wayland.XWindows.WindowCreated(wayland.XWindow{
+			ID:               window,
+			X:                int32(int16(order.Uint16(event[12:]))),
+			Y:                int32(int16(order.Uint16(event[14:]))),
+			Width:            uint32(order.Uint16(event[16:])),
+			Height:           uint32(order.Uint16(event[18:])),
+			OverrideRedirect: event[22] != 0,
+		})
+		_ = wm.Conn.SelectInput(window, PropertyChangeMask)
+		wm.UpdateTitle(window)
+	case DestroyNotify:
+		if order.Uint32(event[4:]) != root {
+			return
+		}
+		wayland.XWindows.WindowDestroyed(order.Uint32(event[8:]))
+	case MapRequest:
+		_ = wm.Conn.MapWindow(order.Uint32(event[8:]))
+	case ConfigureRequest:
+		/**
+		 * Windows go where they ask to, the values
+		 * in the event are in mask bit order.
+		 */
+		window := order.Uint32(event[8:])
+		mask := order.Uint16(event[26:]) & 0x7f
+		fields := []uint32{
+			uint32(int32(int16(order.Uint16(event[16:])))),
+			uint32(int32(int16(order.Uint16(event[18:])))),
+			uint32(order.Uint16(event[20:])),
+			uint32(order.Uint16(event[22:])),
+			uint32(order.Uint16(event[24:])),
+			order.Uint32(event[12:]),
+			uint32(event[1]),
+		}
+		values := make([]uint32, 0, len(fields))
+		for bit, value := range fields {
+			if mask&(1<<bit) != 0 {
+				values = append(values, value)
+			}
+		}
+		_ = wm.Conn.ConfigureWindow(window, mask, values...)
+	case ConfigureNotify:
+		if order.Uint32(event[4:]) != root {
+			return
+		}
+		wayland.XWindows.WindowConfigured(
+			order.Uint32(event[8:]),
+			int32(int16(order.Uint16(event[16:]))),
+			int32(int16(order.Uint16(event[18:]))),
+			uint32(order.Uint16(event[20:])),
+			uint32(order.Uint16(event[22:])),
+		)
+	case PropertyNotify:
+		atom := order.Uint32(event[8:])
+		if atom == AtomWMName || atom == wm.NetWMName {
+			wm.UpdateTitle(order.Uint32(event[4:]))
+		}
+	case ClientMessage:
+		if order.Uint32(event[8:]) != wm.WlSurfaceSerial || event[1] != 32 {
+			return
+		}
+		low := order.Uint32(event[12:])
+		hi := order.Uint32(event[16:])
+		wayland.XWindows.WindowSerial(
+			order.Uint32(event[4:]),
+			wayland.XWaylandSurfaceV1Serial{Low: low, Hi: hi}.Value(),
+		)
+	}
+}
+
+/**
+ * Read _NET_WM_NAME, or WM_NAME if it isn't set
+ */
+func (wm *WindowManager) UpdateTitle(window uint32) {
+	property, err := wm.Conn.GetProperty(window, wm.NetWMName, wm.UTF8String)
+	if err != nil {
+		return
+	}
+	if property.Format == 8 && len(property.Value) > 0 {
+		wayland.XWindows.WindowTitle(window, string(property.Value))
+		return
+	}
+	property, err = wm.Conn.GetProperty(window, AtomWMName, AnyPropertyType)
+	if err != nil || property.Format != 8 || len(property.Value) == 0 {
+		return
+	}
+	if property.Type != AtomString {
+		/**
+		 * COMPOUND_TEXT, close enough for ascii titles
+		 */
+		wayland.XWindows.WindowTitle(window, string(property.Value))
+		return
+	}
+	/**
+	 * STRING is latin-1
+	 */
+	var sb strings.Builder
+	for _, b := range property.Value {
+		sb.WriteRune(rune(b))
+	}
+	wayland.XWindows.WindowTitle(window, sb.String())
+}
+
+/**
+ * Called by the compositor when the window's
+ * surface gets keyboard focus.
+ */
+func (wm *WindowManager) Activate(window uint32) {
+	if err := wm.Conn.ConfigureWindow(window, ConfigWindowStackMode, StackModeAbove); err != nil {
+		fmt.Fprintf(os.Stderr, "xwm: failed to raise window %d: %v\n", window, err)
+		return
+	}
+	_ = wm.Conn.SetInputFocus(window)
+}